@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	"rfc2119/sendall/sendall"
+)
+
+// serviceConfig : option name -> value, for one service
+type serviceConfig map[string]interface{}
+
+// configFile : layout of config.yaml
+//
+//	db: ~/uploads.db
+//	profile: work               # used when --profile is not given
+//	services:                   # defaults of every service
+//	  transfer:
+//	    host: https://transfer.example.com
+//	profiles:                   # override the defaults above
+//	  work:
+//	    privatebin:
+//	      host: https://bin.work.example.com
+type configFile struct {
+	DB       string                              `yaml:"db"`
+	Profile  string                              `yaml:"profile"`
+	Services map[string]serviceConfig            `yaml:"services"`
+	Profiles map[string]map[string]serviceConfig `yaml:"profiles"`
+}
+
+var config configFile
+
+func defaultConfigFile() string {
+	configHome, err := os.UserConfigDir() // $XDG_CONFIG_HOME or ~/.config
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configHome, "sendall", "config.yaml")
+}
+
+func initConfig() {
+	path := cfgFile
+	if path == "" {
+		if path = defaultConfigFile(); path == "" {
+			return
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return // having no config at all is fine
+		}
+	}
+	content, err := ioutil.ReadFile(path)
+	if err == nil {
+		err = yaml.UnmarshalStrict(content, &config)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config %s: %s\n", path, err)
+		os.Exit(1)
+	}
+
+	if profile == "" {
+		if profile = os.Getenv("SENDALL_PROFILE"); profile == "" {
+			profile = config.Profile
+		}
+	}
+	if _, ok := config.Profiles[profile]; profile != "" && ok == false {
+		fmt.Fprintf(os.Stderr, "config %s: no such profile %s\n", path, profile)
+		os.Exit(1)
+	}
+
+	// --db and SENDALL_DB win over the config
+	if rootCmd.PersistentFlags().Changed("db") == false && os.Getenv("SENDALL_DB") == "" && config.DB != "" {
+		if dbName, err = configPath(config.DB, path); err != nil {
+			fmt.Fprintf(os.Stderr, "config %s: db: %s\n", path, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// configPath resolves a path written in the config: a leading ~/ is the home directory, and
+// relative paths are taken from the directory of the config file rather than wherever sendall runs
+func configPath(value, configFile string) (string, error) {
+	if value == "~" || strings.HasPrefix(value, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, value[1:]), nil
+	}
+	if filepath.IsAbs(value) {
+		return value, nil
+	}
+	dir, err := filepath.Abs(filepath.Dir(configFile))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, value), nil
+}
+
+// configValue looks an option up in the chosen profile, then in the service defaults of the config
+func configValue(service, option string) (interface{}, bool) {
+	if value, ok := config.Profiles[profile][service][option]; ok {
+		return value, true
+	}
+	value, ok := config.Services[service][option]
+	return value, ok
+}
+
+// envName is the environment variable of a service option, e.g. SENDALL_TRANSFER_HOST
+func envName(service, option string) string {
+	return strings.ToUpper("SENDALL_" + strings.ReplaceAll(service+"_"+option, "-", "_"))
+}
+
+// parseOption converts a value from the environment or the config to the type of the option
+func parseOption(option sendall.Option, value interface{}) (interface{}, error) {
+	text := fmt.Sprint(value)
+	switch option.Default.(type) {
+	case int:
+		return strconv.Atoi(text)
+	case bool:
+		return strconv.ParseBool(text)
+	default:
+		return text, nil
+	}
+}
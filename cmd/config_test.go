@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"rfc2119/sendall/sendall"
+)
+
+// withConfig points sendall at a config file holding content for the length of the test
+func withConfig(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "sendall")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.yaml")
+	if err = ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	oldCfgFile, oldProfile, oldDbName := cfgFile, profile, dbName
+	cfgFile, profile, config = path, "", configFile{}
+	t.Cleanup(func() {
+		cfgFile, profile, dbName, config = oldCfgFile, oldProfile, oldDbName, configFile{}
+		os.RemoveAll(dir)
+	})
+	return dir
+}
+
+func TestConfigDB(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip(err)
+	}
+	var tests = []struct {
+		db   string
+		want func(configDir string) string
+	}{
+		{"~/x/sendall.db", func(string) string { return filepath.Join(home, "x", "sendall.db") }},
+		{"x/sendall.db", func(configDir string) string { return filepath.Join(configDir, "x", "sendall.db") }},
+		{"/var/lib/sendall.db", func(string) string { return "/var/lib/sendall.db" }},
+	}
+	os.Unsetenv("SENDALL_DB")
+	for _, test := range tests {
+		configDir := withConfig(t, "db: "+test.db+"\n")
+		initConfig()
+		if want := test.want(configDir); dbName != want {
+			t.Errorf("db: %s: got %s, expected %s", test.db, dbName, want)
+		}
+	}
+}
+
+func TestOptionPrecedence(t *testing.T) {
+	backend := sendall.Backend{
+		Name:    "fake",
+		Options: []sendall.Option{{Name: "host", Default: "default"}},
+	}
+	withConfig(t, `
+profile: work
+services:
+  fake:
+    host: services
+profiles:
+  work:
+    fake:
+      host: profile
+  home: {}
+`)
+	const env = "SENDALL_FAKE_HOST"
+	defer os.Unsetenv(env)
+
+	var tests = []struct {
+		name    string
+		flag    string
+		env     string
+		profile string
+		want    string
+	}{
+		{"flag", "flag", "env", "", "flag"},
+		{"env", "", "env", "", "env"},
+		{"profile", "", "", "", "profile"},
+		{"services", "", "", "home", "services"},
+		{"default", "", "", "", "default"},
+	}
+	for _, test := range tests {
+		config = configFile{}
+		profile = test.profile
+		initConfig()
+		if test.name == "default" {
+			config = configFile{} // nothing in the config either
+		}
+		os.Setenv(env, test.env)
+
+		cmd := newServiceCommand(backend)
+		var args []string
+		if test.flag != "" {
+			args = append(args, "--host", test.flag)
+		}
+		if err := cmd.ParseFlags(args); err != nil {
+			t.Fatal(err)
+		}
+		values, err := optionValues(cmd, backend)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		svcValues := sendall.Values{"host": backend.Options[0].Default}
+		for name, value := range values {
+			svcValues[name] = value
+		}
+		if got := svcValues.String("host"); got != test.want {
+			t.Errorf("%s: got %s, expected %s", test.name, got, test.want)
+		}
+	}
+}
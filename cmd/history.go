@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"rfc2119/sendall/sendall"
+)
+
+const legacyDbName = "sendall.db" // older versions created the db in the working directory
+
+var (
+	gcProbe        bool
+	pruneOlderThan time.Duration
+	pruneService   string
+
+	historyCmd = &cobra.Command{
+		Use:   "history",
+		Short: "inspect the uploads remembered in the local db",
+	}
+
+	historyListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "list every upload",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			records, err := sendall.NewStore(dbName).List()
+			if err != nil {
+				return err
+			}
+			if outputFormat != outputText {
+				for _, rec := range records {
+					printRecord(rec)
+				}
+				return nil
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "SERVICE\tFILE\tSIZE\tUPLOADED\tEXPIRES\tDOWNLOADS\tURL\tDELETE URL")
+			for _, rec := range records {
+				deleteUrl := rec.DeleteURL
+				if deleteUrl == "" {
+					deleteUrl = "-"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", rec.Service, baseName(rec.File), humanSize(rec.Size),
+					humanTime(rec.UploadedAt), expiry(rec), downloadLimit(rec.MaxDownloads), rec.URL, deleteUrl)
+			}
+			return w.Flush()
+		},
+	}
+
+	historyShowCmd = &cobra.Command{
+		Use:   "show <url>...",
+		Short: "show everything remembered about an upload",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			allOk := true
+			store := sendall.NewStore(dbName)
+			for _, url := range args {
+				rec, err := store.Get(url)
+				if err != nil {
+					printInfo("%s\n", err)
+					allOk = false
+					continue
+				}
+				if outputFormat != outputText {
+					printRecord(rec)
+					continue
+				}
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+				fmt.Fprintf(w, "url:\t%s\n", rec.URL)
+				fmt.Fprintf(w, "service:\t%s\n", rec.Service)
+				fmt.Fprintf(w, "file:\t%s\n", rec.File)
+				fmt.Fprintf(w, "size:\t%s\n", humanSize(rec.Size))
+				fmt.Fprintf(w, "uploaded:\t%s\n", humanTime(rec.UploadedAt))
+				fmt.Fprintf(w, "expires:\t%s\n", expiry(rec))
+				fmt.Fprintf(w, "downloads:\t%s\n", downloadLimit(rec.MaxDownloads))
+				fmt.Fprintf(w, "delete url:\t%s\n", rec.DeleteURL)
+				if rec.SHA256 != "" {
+					fmt.Fprintf(w, "sha256:\t%s\n", rec.SHA256)
+				}
+				w.Flush()
+			}
+			if allOk == false {
+				return fmt.Errorf("one or more uploads are not in the history")
+			}
+			return nil
+		},
+	}
+
+	historyRmCmd = &cobra.Command{
+		Use:   "rm <url>...",
+		Short: "forget an upload (the file stays on the server; use \"<service> delete\" for that)",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			allOk := true
+			store := sendall.NewStore(dbName)
+			for _, url := range args {
+				rec, err := store.Get(url)
+				if err == nil {
+					err = store.Remove(rec.Service, rec.URL)
+				}
+				printDelete(rec.Service, url, err)
+				allOk = allOk && err == nil
+			}
+			if allOk == false {
+				return fmt.Errorf("one or more uploads were not forgotten")
+			}
+			return nil
+		},
+	}
+
+	historyImportCmd = &cobra.Command{
+		Use:   "import [db]...",
+		Short: "import the uploads of a db left behind by older versions (./sendall.db by default)",
+		Args:  cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				args = []string{legacyDbName}
+			}
+			allOk := true
+			store := sendall.NewStore(dbName)
+			for _, path := range args {
+				imported, err := store.Import(path)
+				if err != nil {
+					printInfo("%s\n", err)
+					allOk = false
+					continue
+				}
+				// keep the old file around, but out of the way so we don't import it twice
+				if err = os.Rename(path, path+".imported"); err != nil {
+					printInfo("%s\n", err)
+					allOk = false
+				}
+				printInfo("imported %d upload(s) from %s into %s\n", imported, path, dbName)
+			}
+			if allOk == false {
+				return fmt.Errorf("one or more dbs were not imported")
+			}
+			return nil
+		},
+	}
+
+	historyGcCmd = &cobra.Command{
+		Use:   "gc",
+		Short: "forget uploads that expired",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dropped, err := sendall.NewStore(dbName).GC(cmd.Context(), gcProbe)
+			return printDropped(dropped, err)
+		},
+	}
+
+	historyPruneCmd = &cobra.Command{
+		Use:   "prune",
+		Short: "forget old uploads",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cutoff := time.Now().Add(-pruneOlderThan)
+			dropped, err := sendall.NewStore(dbName).Prune(func(rec sendall.Record) bool {
+				if pruneService != "" && rec.Service != pruneService {
+					return false
+				}
+				return rec.UploadedAt.Before(cutoff)
+			})
+			return printDropped(dropped, err)
+		},
+	}
+)
+
+func init() {
+	historyGcCmd.Flags().BoolVar(&gcProbe, "probe", false, "ask the services whether the files are still there (e.g. burned by their download limit)")
+	historyPruneCmd.Flags().DurationVar(&pruneOlderThan, "older-than", 30*24*time.Hour, "forget uploads older than this")
+	historyPruneCmd.Flags().StringVar(&pruneService, "service", "", "only forget uploads to this service (e.g. transfer.sh, privateBin)")
+	historyCmd.AddCommand(historyListCmd, historyShowCmd, historyRmCmd, historyImportCmd, historyGcCmd, historyPruneCmd)
+	rootCmd.AddCommand(historyCmd)
+}
+
+// printDropped reports the records forgotten by gc and prune, or passes their error on
+func printDropped(dropped []sendall.Record, err error) error {
+	if err != nil {
+		return err
+	}
+	for _, rec := range dropped {
+		if outputFormat == outputText {
+			fmt.Printf("forgot %s\n", rec.URL)
+			continue
+		}
+		printRecord(rec)
+	}
+	printInfo("forgot %d upload(s)\n", len(dropped))
+	return nil
+}
+
+func baseName(path string) string {
+	if path == "" {
+		return "-"
+	}
+	return filepath.Base(path)
+}
+
+func humanSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+func humanTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// expiry is when the upload expires, or that it is gone already
+func expiry(rec sendall.Record) string {
+	if rec.GoneAt.IsZero() == false {
+		return "gone " + humanTime(rec.GoneAt)
+	}
+	return humanTime(rec.ExpiresAt)
+}
+
+func downloadLimit(maxDownloads int) string {
+	if maxDownloads <= 0 {
+		return "unlimited"
+	}
+	return fmt.Sprint(maxDownloads)
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"rfc2119/sendall/sendall"
+)
+
+const (
+	outputText    = "text"
+	outputJson    = "json"
+	outputUrlOnly = "url-only"
+)
+
+var outputFormat string // picked in the root's PersistentPreRun when not given: text on a terminal, url-only in pipe mode
+
+// fileOutput : one line of --output json; one per file uploaded, deleted or listed
+type fileOutput struct {
+	Service      string     `json:"service"`
+	File         string     `json:"file,omitempty"`
+	Size         int64      `json:"size,omitempty"`
+	URL          string     `json:"url"`
+	DeleteURL    string     `json:"delete_url,omitempty"`
+	Key          string     `json:"key,omitempty"`
+	UploadedAt   *time.Time `json:"uploaded_at,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	MaxDownloads int        `json:"max_downloads,omitempty"`
+	SHA256       string     `json:"sha256,omitempty"`
+	Deleted      bool       `json:"deleted,omitempty"`
+	Gone         bool       `json:"gone,omitempty"` // already deleted, expired or burned
+	Error        string     `json:"error,omitempty"`
+}
+
+func checkOutputFormat() error {
+	switch outputFormat {
+	case "":
+		if pipeMode {
+			outputFormat = outputUrlOnly
+		} else {
+			outputFormat = outputText
+		}
+	case outputText, outputJson, outputUrlOnly:
+	default:
+		return fmt.Errorf("unknown output format %s; values: [text, json, url-only]", outputFormat)
+	}
+	return nil
+}
+
+func printJson(out fileOutput) {
+	line, _ := json.Marshal(out)
+	fmt.Println(string(line))
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// printUpload reports the outcome of one upload
+func printUpload(upload sendall.UploadRequest, result sendall.UploadResult, err error) {
+	switch outputFormat {
+	case outputJson:
+		printJson(fileOutput{Service: result.Service, File: upload.Path, Size: result.Size, URL: result.URL, DeleteURL: result.DeleteURL,
+			Key: result.Key, ExpiresAt: timeOrNil(result.ExpiresAt), MaxDownloads: result.MaxDownloads, SHA256: result.SHA256, Error: errorString(err)})
+	case outputUrlOnly:
+		if err != nil {
+			printInfo("%s: %s\n", upload.Path, err)
+			return
+		}
+		fmt.Println(result.URL)
+	default:
+		if err != nil {
+			printInfo("%s: %s\n", upload.Path, err)
+			return
+		}
+		fmt.Printf("url: %s\ndelete url: %s\n", result.URL, result.DeleteURL)
+	}
+}
+
+// printDelete reports the outcome of one delete
+func printDelete(service, url string, err error) {
+	switch outputFormat {
+	case outputJson:
+		out := fileOutput{Service: service, URL: url, Deleted: err == nil, Error: errorString(err)}
+		if err == sendall.ErrGone {
+			out.Gone, out.Error = true, ""
+		}
+		printJson(out)
+	default:
+		if err == sendall.ErrGone {
+			printInfo("%s: %s; forgetting it\n", url, err)
+			return
+		}
+		if err != nil {
+			printInfo("%s: %s\n", url, err)
+			return
+		}
+		if outputFormat == outputUrlOnly {
+			fmt.Println(url)
+		}
+	}
+}
+
+// printRecord prints a record of the history store in the json or url-only formats; text is left to the caller
+func printRecord(rec sendall.Record) {
+	if outputFormat == outputJson {
+		printJson(fileOutput{Service: rec.Service, File: rec.File, Size: rec.Size, URL: rec.URL, DeleteURL: rec.DeleteURL,
+			UploadedAt: timeOrNil(rec.UploadedAt), ExpiresAt: timeOrNil(rec.ExpiresAt), MaxDownloads: rec.MaxDownloads, SHA256: rec.SHA256, Gone: rec.GoneAt.IsZero() == false})
+		return
+	}
+	fmt.Println(rec.URL)
+}
+
+// printInfo prints anything that is not a result; it goes to stderr unless the output is text
+func printInfo(format string, a ...interface{}) {
+	if outputFormat != outputText {
+		fmt.Fprintf(os.Stderr, format, a...)
+		return
+	}
+	fmt.Printf(format, a...)
+}
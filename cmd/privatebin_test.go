@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"rfc2119/sendall/sendall"
+)
+
+func TestBurnReason(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sendall")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := sendall.NewStore(filepath.Join(dir, "sendall.db"))
+	posted := sendall.UploadResult{Service: "privatebin", File: "notes.txt", URL: "https://bin.example.com/?0123456789abcdef#key", MaxDownloads: 1}
+	if err = store.Save(posted); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		url  string
+		burn bool
+	}{
+		{"https://bin.example.com/?fedcba9876543210#-key", true}, // what sendall hands out for --burn-after-reading on privatebin 1.6+
+		{"https://bin.example.com/?fedcba9876543210#key", false},
+		{posted.URL, true}, // by the history, for links from before the prefix
+	}
+	for _, test := range tests {
+		// get refuses to go on without --burn-confirm when there's a reason
+		if reason := burnReason(store, test.url); (reason != "") != test.burn {
+			t.Errorf("%s: got reason %q", test.url, reason)
+		}
+	}
+}
+
+// captureStdout returns what fn prints
+func captureStdout(t *testing.T, fn func()) string {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+	fn()
+	writer.Close()
+	printed, _ := ioutil.ReadAll(reader)
+	return string(printed)
+}
+
+func TestPrintPasteJson(t *testing.T) {
+	oldFormat, oldComments := outputFormat, showComments
+	outputFormat, showComments = outputJson, true
+	defer func() { outputFormat, showComments = oldFormat, oldComments }()
+
+	created := time.Unix(1600000000, 0).UTC()
+	paste := sendall.Paste{Id: "0123456789abcdef", Text: "hi\n", Format: "plaintext", BurnAfterReading: 1,
+		Comments: []sendall.Comment{{Id: "c1", ParentId: "0123456789abcdef", Text: "welp", Created: created}}}
+	var attachment string
+	printed := captureStdout(t, func() {
+		attachment, _ = writePaste(paste) // the text goes in the json line, not before it
+		printPasteJson("privateBin", "https://bin.example.com/?0123456789abcdef#-key", paste, attachment, nil)
+	})
+	var out pasteOutput
+	if err := json.Unmarshal([]byte(printed), &out); err != nil {
+		t.Fatalf("%s: %q", err, printed)
+	}
+	if out.Text != "hi\n" || out.Burned == false || len(out.Comments) != 1 || out.Comments[0].Text != "welp" || out.Comments[0].Created.Equal(created) == false {
+		t.Errorf("got %+v", out)
+	}
+}
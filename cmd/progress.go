@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"rfc2119/sendall/sendall"
+)
+
+const (
+	progressRedraw   = 100 * time.Millisecond // how often bars are redrawn at most
+	progressInterval = time.Second            // how often json events are printed per file at most
+	progressBarWidth = 20
+	progressNameMax  = 24
+)
+
+// progress follows a batch of uploads on stderr: a line per file on a terminal, redrawn in place,
+// or json events with --output json. stdout is left to the results
+type progress struct {
+	mu      sync.Mutex // uploads report from their own goroutines
+	json    bool
+	out     io.Writer
+	files   []*fileProgress
+	drawn   int       // lines drawn last time, which the next redraw goes back over
+	drawnAt time.Time // of the last redraw
+}
+
+type fileProgress struct {
+	name        string
+	sent, total int64 // total is -1 until known
+	started     time.Time
+	finished    time.Time // when the file was read through; zero until then
+	reportedAt  time.Time // of the last json event
+}
+
+// progressEvent : a line of --output json telling how far an upload got
+type progressEvent struct {
+	Event          string `json:"event"` // always "progress"
+	File           string `json:"file"`
+	Sent           int64  `json:"sent"`
+	Total          int64  `json:"total"`                 // -1 if unknown
+	BytesPerSecond int64  `json:"bytes_per_second"`      // average since the upload started
+	ETASeconds     int64  `json:"eta_seconds,omitempty"` // left out when the total is unknown, and once done
+}
+
+// trackProgress hooks every upload up to a progress display, if there is anywhere to show it: json events
+// with --output json, bars when stderr is a terminal. it returns nil otherwise
+func trackProgress(uploads []sendall.UploadRequest) *progress {
+	p := &progress{json: outputFormat == outputJson, out: os.Stderr}
+	if p.json == false && isTerminal(os.Stderr) == false {
+		return nil
+	}
+	for i := range uploads {
+		file := &fileProgress{name: progressName(uploads[i]), total: -1}
+		p.files = append(p.files, file)
+		uploads[i].Progress = func(sent, total int64) {
+			p.update(file, sent, total)
+		}
+	}
+	p.draw(true)
+	return p
+}
+
+func progressName(upload sendall.UploadRequest) string {
+	switch {
+	case upload.Name != "":
+		return upload.Name
+	case upload.Path != "":
+		return filepath.Base(upload.Path)
+	default:
+		return "stdin"
+	}
+}
+
+func (p *progress) update(file *fileProgress, sent, total int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	if sent == 0 { // started, or started over by a retry
+		file.started, file.finished = now, time.Time{}
+	} else if file.finished.IsZero() == false { // told of the end of the file once more
+		return
+	}
+	file.sent, file.total = sent, total
+	done := sent == total
+	if done {
+		file.finished = now
+	}
+	if p.json {
+		if done || now.Sub(file.reportedAt) >= progressInterval {
+			file.reportedAt = now
+			p.report(file)
+		}
+		return
+	}
+	p.draw(done)
+}
+
+// finish draws the bars one last time, so the results printed next start on a line of their own
+func (p *progress) finish() {
+	if p == nil || p.json {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.draw(true)
+}
+
+func (p *progress) report(file *fileProgress) {
+	event := progressEvent{Event: "progress", File: file.name, Sent: file.sent, Total: file.total, BytesPerSecond: int64(file.rate())}
+	if eta, ok := file.eta(); ok {
+		event.ETASeconds = int64(eta.Seconds())
+	}
+	line, _ := json.Marshal(event)
+	fmt.Fprintln(p.out, string(line))
+}
+
+// draw goes back over the lines drawn last time and draws every file again; at most every progressRedraw, unless forced
+func (p *progress) draw(force bool) {
+	if p.json || (force == false && time.Since(p.drawnAt) < progressRedraw) {
+		return
+	}
+	p.drawnAt = time.Now()
+	var screen strings.Builder
+	if p.drawn > 0 {
+		fmt.Fprintf(&screen, "\x1b[%dA", p.drawn) // cursor up
+	}
+	for _, file := range p.files {
+		fmt.Fprintf(&screen, "\r\x1b[K%s\n", file.line()) // \x1b[K clears what's left of the old line
+	}
+	p.drawn = len(p.files)
+	io.WriteString(p.out, screen.String())
+}
+
+// line is e.g. "notes.txt  [=========>          ]  48%  4.8MB/10.0MB  1.2MB/s  eta 0:04"
+func (file *fileProgress) line() string {
+	name := file.name
+	if len(name) > progressNameMax {
+		name = name[:progressNameMax-3] + "..."
+	}
+	line := fmt.Sprintf("%-*s  ", progressNameMax, name)
+	switch {
+	case file.started.IsZero():
+		return line + "waiting"
+	case file.total < 0:
+		return line + fmt.Sprintf("%s  %s/s", humanSize(file.sent), humanSize(int64(file.rate())))
+	}
+
+	ratio := 1.0
+	if file.total > 0 {
+		ratio = float64(file.sent) / float64(file.total)
+	}
+	filled := int(ratio * progressBarWidth)
+	bar := strings.Repeat("=", filled)
+	if filled < progressBarWidth {
+		bar += ">" + strings.Repeat(" ", progressBarWidth-filled-1)
+	}
+	line += fmt.Sprintf("[%s] %3.0f%%  %s/%s  %s/s", bar, ratio*100, humanSize(file.sent), humanSize(file.total), humanSize(int64(file.rate())))
+	if eta, ok := file.eta(); ok && file.sent < file.total {
+		line += fmt.Sprintf("  eta %d:%02d", int(eta.Minutes()), int(eta.Seconds())%60)
+	}
+	return line
+}
+
+// rate is the average throughput since the upload started, in bytes per second
+func (file *fileProgress) rate() float64 {
+	end := file.finished
+	if end.IsZero() {
+		end = time.Now()
+	}
+	elapsed := end.Sub(file.started).Seconds()
+	if file.started.IsZero() || elapsed <= 0 {
+		return 0
+	}
+	return float64(file.sent) / elapsed
+}
+
+func (file *fileProgress) eta() (time.Duration, bool) {
+	rate := file.rate()
+	if file.total < 0 || rate <= 0 {
+		return 0, false
+	}
+	return time.Duration(float64(file.total-file.sent) / rate * float64(time.Second)), true
+}
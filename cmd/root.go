@@ -1,25 +1,19 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 
-	// homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 )
 
-// all services should implement this
-type service interface {
-	// TODO: there should be some method that Post() uses on all files; this method should be a candidate as a goroutine
-	Post(receivedHttpResponses chan<- *http.Response, extra chan<- []string) error    // constructs a new http request with requested files and send it; the response is sent to the channel receivedHttpResponses in order for the save method to save deletion tokens; the channel "extra" is used to communicate any extra information  necessary for the save method to operation
-	SaveUrl(receivedHttpResponses <-chan *http.Response, extra <-chan []string) error // saves deletion tokens obtained from the service in local db
-	Delete() error                                                                    // fetches urls:deletion_tokens from local db (saved by the save method) and issue a delete request to the service, then deletes the record from the db
-}
-
 var (
 	// Used for flags.
-	// cfgFile     string
+	cfgFile string
+	profile string
 	// userLicense string
 
 	rootCmd = &cobra.Command{
@@ -36,28 +30,45 @@ var (
 )
 
 func init() {
-	// cobra.OnInitialize(initConfig)
-
-}
-
-func initConfig() {
-	// if cfgFile != "" {
-	// 	// Use config file from the flag.
-	// } else {
-	// 	// Find home directory.
-	// 	home, err := homedir.Dir()
-	// 	if err != nil {
-	// 		er(err)
-	// 	}
-	// fmt.Println("found home %s", home)
-	// 	// Search config in home directory with name ".cobra" (without extension).
-	// }
+	cobra.OnInitialize(initConfig)
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default "+defaultConfigFile()+")")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "profile of the config file to use (env SENDALL_PROFILE)")
+	rootCmd.PersistentFlags().StringVar(&dbName, "db", dbName, "history db file (env SENDALL_DB)")
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output", "", "output format; values: [text, json, url-only] (default text, or url-only when piped)")
+	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		if err := checkOutputFormat(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		// the command line is fine by now; errors from here on are not helped by the usage
+		cmd.SilenceUsage = true
 
+		// dbs used to be created in whatever directory sendall was run from
+		if cmd == historyImportCmd {
+			return
+		}
+		if stray, err := filepath.Abs(legacyDbName); err == nil && stray != dbName {
+			if _, err = os.Stat(stray); err == nil {
+				// a hint, not output: keep it out of whatever stdout is piped into
+				fmt.Fprintf(os.Stderr, "found %s from an older version; run \"sendall history import\" to keep its delete links\n", stray)
+			}
+		}
+	}
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	// ctrl-c cancels whatever uploads or deletes are in flight
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		cancel()
+	}()
+
+	addServiceCommands()
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		os.Exit(1) // cobra printed the error on stderr already
 	}
 }
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"rfc2119/sendall/sendall"
+)
+
+var dbName = defaultDbName() // bolt db name; each service gets its own bucket inside, mapping posted urls -> records
+
+// defaultDbName picks $SENDALL_DB if set, otherwise the xdg data dir
+func defaultDbName() string {
+	if name := os.Getenv("SENDALL_DB"); name != "" {
+		return name
+	}
+	return sendall.DefaultStorePath()
+}
+
+// runUpload is what every "sendall <service> <files>" command does; parallel uploads run at once
+func runUpload(ctx context.Context, svc sendall.Service, uploads []sendall.UploadRequest, parallel int) error {
+	allOk := true
+	store := sendall.NewStore(dbName)
+	progress := trackProgress(uploads)
+	results, errs := sendall.UploadParallel(ctx, svc, uploads, parallel)
+	progress.finish()
+	for i, result := range results {
+		printUpload(uploads[i], result, errs[i])
+		if errs[i] != nil {
+			allOk = false
+			continue
+		}
+		if err := store.Save(result); err != nil {
+			printInfo("error on writing %s: %s\n", result.URL, err)
+			allOk = false
+		}
+	}
+	if allOk == false {
+		return fmt.Errorf("one or more files were not uploaded")
+	}
+	return nil
+}
+
+// runBundle is "sendall <service> --form <files>": the service itself bundles the files into one archive
+func runBundle(ctx context.Context, svc sendall.Service, uploads []sendall.UploadRequest, format string) error {
+	bundler, ok := svc.(sendall.Bundler)
+	if ok == false {
+		return fmt.Errorf("%s can't bundle files by itself; drop --form to build the archive locally", svc.Name())
+	}
+	result, err := bundler.UploadBundle(ctx, uploads, format)
+	printUpload(sendall.UploadRequest{Path: result.File}, result, err)
+	if err != nil {
+		return fmt.Errorf("the bundle was not uploaded")
+	}
+	return sendall.NewStore(dbName).Save(result)
+}
+
+// runDelete is what every "sendall <service> delete <urls>" command does
+func runDelete(ctx context.Context, svc sendall.Service, urls []string) error {
+	allOk := true
+	store := sendall.NewStore(dbName)
+	for _, url := range urls { // urls provided should be the exact received urls
+		rec, err := store.Find(svc.Name(), url)
+		if err == nil {
+			err = svc.Delete(ctx, rec)
+		}
+		printDelete(svc.Name(), url, err)
+		if err != nil && err != sendall.ErrGone { // nothing left on the server to keep the record for
+			allOk = false
+			continue
+		}
+		if err = store.Remove(svc.Name(), url); err != nil {
+			printInfo("error deleting link %s from db: %s\n", url, err)
+			allOk = false
+		}
+	}
+	if allOk == false {
+		return fmt.Errorf("one or more files were not deleted")
+	}
+	printInfo("done\n")
+	return nil
+}
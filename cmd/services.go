@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"rfc2119/sendall/sendall"
+)
+
+// serviceSubcommands : commands only one service has (e.g. "privatebin get"), keyed by backend name
+var serviceSubcommands = map[string][]*cobra.Command{}
+
+var servicesCmd = &cobra.Command{
+	Use:   "services",
+	Short: "list the supported services and what they can do",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, backend := range sendall.Backends() {
+			if outputFormat == outputJson {
+				line, _ := json.Marshal(backend)
+				fmt.Println(string(line))
+				continue
+			}
+			if outputFormat == outputUrlOnly {
+				fmt.Println(backend.Name)
+				continue
+			}
+			fmt.Printf("%s\t%s\n", backend.Name, backend.Description)
+			fmt.Printf("\tcapabilities: %s\n", strings.Join(capabilityNames(backend.Capabilities), ", "))
+			for _, option := range backend.Options {
+				fmt.Printf("\t--%s (default %v)\n", option.Name, option.Default)
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(servicesCmd)
+}
+
+// addServiceCommands builds "sendall <service>" and its subcommands for every registered backend;
+// called from Execute() so backends registered by other packages' init() are picked up too
+func addServiceCommands() {
+	for _, backend := range sendall.Backends() {
+		rootCmd.AddCommand(newServiceCommand(backend))
+	}
+}
+
+func newServiceCommand(backend sendall.Backend) *cobra.Command {
+	var (
+		remoteName, archiveFormat string
+		form                      bool
+		parallel                  int
+	)
+
+	serviceCmd := &cobra.Command{
+		Use:   backend.Name + " [file|-]...",
+		Short: backend.Description,
+		Args:  cobra.ArbitraryArgs, // no args reads stdin
+		RunE: func(cmd *cobra.Command, args []string) error {
+			svc, err := newService(cmd, backend)
+			if err != nil {
+				return err
+			}
+			// files are provided straight from the cmd interface; tidy them
+			uploads, err := prepareUploads(args, remoteName)
+			if err != nil {
+				return err
+			}
+			switch {
+			case form:
+				if archiveFormat == "" {
+					archiveFormat = sendall.ArchiveZip
+				}
+				err = runBundle(cmd.Context(), svc, uploads, archiveFormat)
+			case archiveFormat != "":
+				if archivesStdin(uploads) {
+					err = fmt.Errorf("--archive only takes files and directories, not stdin")
+					break
+				}
+				var archive sendall.UploadRequest
+				if archive, err = sendall.NewArchive(prepareFiles(args), archiveFormat); err == nil {
+					if remoteName != "" {
+						archive.Name = remoteName
+					}
+					err = runUpload(cmd.Context(), svc, []sendall.UploadRequest{archive}, 1)
+				}
+			default:
+				err = runUpload(cmd.Context(), svc, uploads, parallel)
+			}
+			return err
+		},
+	}
+	serviceCmd.Flags().StringVar(&remoteName, "name", "", "remote file name of stdin (or of the only file given)")
+	serviceCmd.Flags().StringVar(&archiveFormat, "archive", "", "upload the files and directories as a single archive, built on the fly; values: [zip, tar.gz]")
+	serviceCmd.Flags().IntVar(&parallel, "parallel", sendall.DefaultParallel, "how many files to upload at once")
+	serviceCmd.Flags().BoolVar(&form, "form", false, "let the service build the archive out of a multi-file upload (transfer.sh only)")
+	// persistent, so that subcommands (e.g. delete) see the same options
+	flags := serviceCmd.PersistentFlags()
+	flags.Int("retries", sendall.DefaultRetryPolicy().Retries, "how many times to retry a request that failed on a network error, 429 or 5xx")
+	flags.Duration("timeout", 0, "give up on a request (and retry it) after this long, e.g. 30s; 0 for no limit")
+	for _, option := range backend.Options {
+		switch value := option.Default.(type) {
+		case int:
+			flags.IntP(option.Name, option.Shorthand, value, option.Usage)
+		case bool:
+			flags.BoolP(option.Name, option.Shorthand, value, option.Usage)
+		default:
+			flags.StringP(option.Name, option.Shorthand, fmt.Sprint(value), option.Usage)
+		}
+	}
+
+	if backend.Capabilities.Delete {
+		serviceCmd.AddCommand(&cobra.Command{
+			Use:   "delete",
+			Short: "delete a link posted before",
+			Args:  cobra.MinimumNArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				svc, err := newService(cmd, backend)
+				if err != nil {
+					return err
+				}
+				// it is expected that provided arguments are the exact links you received from the service
+				return runDelete(cmd.Context(), svc, args)
+			},
+		})
+	}
+	serviceCmd.AddCommand(serviceSubcommands[backend.Name]...)
+	return serviceCmd
+}
+
+// newService builds the backend's service out of the flags, environment and config of cmd
+func newService(cmd *cobra.Command, backend sendall.Backend) (sendall.Service, error) {
+	values, err := optionValues(cmd, backend)
+	if err != nil {
+		return nil, err
+	}
+	svc, err := backend.NewService(values)
+	if err != nil {
+		return nil, err
+	}
+	if retrier, ok := svc.(sendall.Retrier); ok {
+		policy := sendall.DefaultRetryPolicy()
+		policy.Retries, _ = cmd.Flags().GetInt("retries")
+		policy.Timeout, _ = cmd.Flags().GetDuration("timeout")
+		if policy.Retries < 0 || policy.Timeout < 0 {
+			return nil, fmt.Errorf("--retries and --timeout can't be negative")
+		}
+		retrier.SetRetryPolicy(policy)
+	}
+	return svc, nil
+}
+
+// optionValues collects the backend's options; flags win over the environment, which wins over the config file.
+// options found nowhere are left out, so they take the backend's defaults
+func optionValues(cmd *cobra.Command, backend sendall.Backend) (sendall.Values, error) {
+	values := make(sendall.Values, len(backend.Options))
+	flags := cmd.Flags() // inherited persistent flags are merged in at parse time
+	for _, option := range backend.Options {
+		if flags.Changed(option.Name) {
+			switch option.Default.(type) {
+			case int:
+				values[option.Name], _ = flags.GetInt(option.Name)
+			case bool:
+				values[option.Name], _ = flags.GetBool(option.Name)
+			default:
+				values[option.Name], _ = flags.GetString(option.Name)
+			}
+			continue
+		}
+
+		var (
+			value  interface{}
+			found  bool
+			source string
+		)
+		if env := envName(backend.Name, option.Name); os.Getenv(env) != "" {
+			value, found, source = os.Getenv(env), true, env
+		} else if value, found = configValue(backend.Name, option.Name); found {
+			source = "config"
+		}
+		if found == false {
+			continue
+		}
+		parsed, err := parseOption(option, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: bad value for --%s: %s", source, option.Name, err)
+		}
+		values[option.Name] = parsed
+	}
+	// secrets given as "-" are asked for, so they stay out of the shell history and the process list
+	for _, option := range backend.Options {
+		if option.Secret && values[option.Name] == stdinName {
+			secret, err := readSecret(option.Name + ": ")
+			if err != nil {
+				return nil, fmt.Errorf("--%s: %s", option.Name, err)
+			}
+			values[option.Name] = secret
+		}
+	}
+	return values, nil
+}
+
+func capabilityNames(capabilities sendall.Capabilities) []string {
+	names := []string{"upload"}
+	if capabilities.Delete {
+		names = append(names, "delete")
+	}
+	if capabilities.Download {
+		names = append(names, "download")
+	}
+	if capabilities.Encryption {
+		names = append(names, "encryption")
+	}
+	if capabilities.MaxSize > 0 {
+		names = append(names, fmt.Sprintf("max size %dMB", capabilities.MaxSize>>20))
+	}
+	return names
+}
@@ -2,298 +2,223 @@ package cmd
 
 import (
 	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"io/ioutil"
-	"net/http"
+	"io"
+	"net/url"
 	"os"
-	"strconv"
-	"sync"
+	"path"
+	"strings"
 
-	"github.com/boltdb/bolt"
 	"github.com/spf13/cobra"
+	"rfc2119/sendall/sendall"
 )
 
-const (
-	serverDev  = "http://127.0.0.1"
-	portDev    = "8090"
-	serverProd = "https://transfer.sh"
+var (
+	downloadOut string
+	verify      bool
+
+	transferGetCmd = &cobra.Command{
+		Use:   "get <url>",
+		Short: "download a file; an interrupted download resumes where it stopped",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			transfer, err := newTransfer(cmd)
+			if err != nil {
+				return err
+			}
+			if downloadOut == stdinName {
+				return downloadToStdout(cmd, transfer, args[0])
+			}
+			return download(cmd, transfer, args[0])
+		},
+	}
 )
 
-type transferSh struct {
-	// previously: a dummy empty struct{} to implement the interface
-
-	// cmd options:
-	hostUrl      string
-	maxDownloads int
-	maxDays      int
-
-	// mandatory members
-	httpClient   *http.Client
-	filePaths    []string // ready and clean to be read from
-	dbName       string
-	dbBucketName string
+func init() {
+	transferGetCmd.Flags().StringVarP(&downloadOut, "out", "o", "", "where to write the file; - for stdout (default: the file's name, in the current directory)")
+	transferGetCmd.Flags().BoolVar(&verify, "verify", false, "check the file against the sha256 recorded in the history when it was uploaded")
+	serviceSubcommands["transfer"] = append(serviceSubcommands["transfer"], transferGetCmd)
+}
 
-	// other
-	debug bool
+func newTransfer(cmd *cobra.Command) (*sendall.TransferSh, error) {
+	backend, _ := sendall.Lookup("transfer")
+	svc, err := newService(cmd, backend)
+	if err != nil {
+		return nil, err
+	}
+	return svc.(*sendall.TransferSh), nil
 }
 
-func (receiver *transferSh) SaveUrl(receivedHttpResponses <-chan *http.Response, extra <-chan []string) error {
+// download writes the file at fileUrl to --out through a .part file, which is resumed if an earlier run left one;
+// files uploaded with --encrypt are decrypted once the .part file is complete
+func download(cmd *cobra.Command, transfer *sendall.TransferSh, fileUrl string) error {
+	key, err := sendall.TransferKey(fileUrl)
+	if err != nil {
+		return err
+	}
+	name := downloadOut
+	if name == "" {
+		parsed, err := url.Parse(fileUrl)
+		if err != nil {
+			return err
+		}
+		if name = path.Base(path.Clean("/" + parsed.Path)); name == "/" {
+			return fmt.Errorf("%s has no file name; pick one with --out", fileUrl)
+		}
+	}
+	if _, err := os.Stat(name); err == nil && downloadOut == "" {
+		return fmt.Errorf("%s already exists; pick another name with --out", name)
+	}
 
-	var (
-		body   []byte
-		db     *bolt.DB
-		bucket *bolt.Bucket
-		err    error
-	)
-	allUrlsOk := true
-	if db, err = bolt.Open(receiver.dbName, 0600, nil); err != nil {
-		fmt.Println("could not open db")
+	part := name + ".part"
+	file, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
 		return err
 	}
-	defer db.Close()
-	err = db.Update(func(tx *bolt.Tx) error {
-		_, err = tx.CreateBucketIfNotExists([]byte(receiver.dbBucketName))
+	defer file.Close()
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
 		return err
-	})
-
+	}
+	body, info, err := transfer.Download(cmd.Context(), fileUrl, offset)
 	if err != nil {
-		fmt.Println("create bucket error")
 		return err
 	}
-	for resp := range receivedHttpResponses { // channel extra is not used here
-
-		defer resp.Body.Close()
-		if body, err = ioutil.ReadAll(resp.Body); err != nil {
-			fmt.Printf("failed to read body: %s", err)
-			allUrlsOk = false
-			continue
+	defer body.Close()
+	if info.Offset != offset { // the server starts over
+		if err = file.Truncate(0); err != nil {
+			return err
 		}
-		// fmt.Printf("%s\ndelete url: %s\n====\n", body, resp.Header.Get("X-Url-Delete"))
-		fmt.Println(string(body)) // body is new url returned by the server
-
-		err = db.Update(func(tx *bolt.Tx) error {
-			bucket = tx.Bucket([]byte(receiver.dbBucketName))
-			err := bucket.Put(body, []byte(resp.Header.Get("X-Url-Delete")))
+		if _, err = file.Seek(0, io.SeekStart); err != nil {
 			return err
-		})
-		if err != nil {
-			fmt.Printf("error on writing %s: %s\n", body, err)
-			allUrlsOk = false
-			continue
 		}
-		fmt.Printf("wrote %s\n", body)
-
+	} else if offset > 0 {
+		printInfo("resuming %s at %s\n", part, humanSize(offset))
 	}
-	if allUrlsOk == false {
-		return fmt.Errorf("one or more URLs were not saved properly")
+	written, err := io.Copy(file, body)
+	if err != nil {
+		return fmt.Errorf("%s; run again to resume", err)
+	}
+	if err = file.Close(); err != nil {
+		return err
 	}
 
-	return nil
-
-}
-
-func (receiver *transferSh) Post(receivedHttpResponses chan<- *http.Response, extra chan<- []string) error {
-
-	var (
-		file        *os.File
-		url         string
-		fileContent *bufio.Reader
-		err         error
-		newRequest  *http.Request
-		holup       sync.WaitGroup
-	)
-	allRequestsOk := true
-	for i := 0; i < len(receiver.filePaths); i++ {
-
-		if file, err = os.Open(receiver.filePaths[i]); err != nil {
-			fmt.Println(err)
-			continue
-		}
-		// url = receiver.hostUrl + file.Name()      // TODO: url need to end in '/'
-		url = receiver.hostUrl + "/" + sanitize(file.Name())       // TODO: imo we only need filepath.Clean(file.Name())
-		fileContent = bufio.NewReader(file)                        // TODO: is this the appropriate way to read a file as an io.Reader ?
-		newRequest, err = http.NewRequest("PUT", url, fileContent) // transfer.sh resolves file path and generates a folder with random name
-		if err != nil {
-			fmt.Println(err)
-			allRequestsOk = false
-			continue
+	sum, err := finishDownload(part, name, key)
+	if err != nil {
+		return err
+	}
+	if verify {
+		if err = checkSHA256(fileUrl, sum); err != nil {
+			os.Remove(name)
+			return err
 		}
-		// adding custom headers
-		newRequest.Header.Add("Max-Downloads", strconv.Itoa(receiver.maxDownloads)) // TODO: Itoa() all the fields ?
-		newRequest.Header.Add("Max-Days", strconv.Itoa(receiver.maxDays))
-
-		holup.Add(1)
-		go func(req *http.Request, c chan<- *http.Response, reqOk *bool) {
-			defer holup.Done()
-			if resp, err := receiver.httpClient.Do(req); err != nil {
-				fmt.Printf("issuing request failed: %s\n", err)
-				*reqOk = false
-			} else {
-				c <- resp
-				// should pass here any extra strings to channel extra, but there is nothing to pass
-			}
-		}(newRequest, receivedHttpResponses, &allRequestsOk)
 	}
-
-	holup.Wait()
-	if allRequestsOk == false {
-		return fmt.Errorf("one or more request failed")
+	size := info.Offset + written
+	if stat, err := os.Stat(name); err == nil {
+		size = stat.Size() // less than what was downloaded, if it was decrypted
+	}
+	summary := fmt.Sprintf("wrote %s (%s)", name, humanSize(size))
+	if left := remaining(info); left != "" {
+		summary += "; " + left
 	}
-	close(receivedHttpResponses)
-	close(extra)
+	printInfo("%s\n", summary)
 	return nil
 }
 
-func (receiver *transferSh) Delete() error {
-
-	var (
-		db        *bolt.DB
-		err       error
-		deleteUrl []byte
-		req       *http.Request
-		resp      *http.Response
-		bucket    *bolt.Bucket
-	)
-	allRequestsOk := true
-
-	// check if the db exists or not, also check the bucket
-	if _, err = os.Stat(receiver.dbName); os.IsNotExist(err) {
-		fmt.Println("delete: db file does not exist")
+// downloadToStdout streams the file at fileUrl to stdout; there is no .part file to resume from, and
+// --verify can only complain once everything is written
+func downloadToStdout(cmd *cobra.Command, transfer *sendall.TransferSh, fileUrl string) error {
+	key, err := sendall.TransferKey(fileUrl)
+	if err != nil {
 		return err
 	}
+	body, info, err := transfer.Download(cmd.Context(), fileUrl, 0)
 	if err != nil {
-		fmt.Println("create bucket error")
 		return err
 	}
-
-	// open db, check if bucket exists; fetch delete link and request deletion
-	if db, err = bolt.Open(receiver.dbName, 0600, nil); err != nil {
-		fmt.Println("could not open db")
-		return err
+	defer body.Close()
+	var plain io.Reader = body
+	if key != nil {
+		if plain, err = sendall.NewDecryptReader(body, key); err != nil {
+			return err
+		}
 	}
-	defer db.Close()
-	err = db.Update(func(tx *bolt.Tx) error {
-		_, err = tx.CreateBucketIfNotExists([]byte(receiver.dbBucketName))
+	hash := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(os.Stdout, hash), plain); err != nil {
 		return err
-	})
-	for _, file := range receiver.filePaths { // files provided should be the exact received url
-		db.View(func(tx *bolt.Tx) error {
-			bucket := tx.Bucket([]byte(receiver.dbBucketName))
-			answer := bucket.Get([]byte(file))
-			deleteUrl = make([]byte, len(answer))
-			copy(deleteUrl, answer)
-
-			return nil
-		})
-		if len(deleteUrl) == 0 {
-			fmt.Printf("link %s does not have an entry in db\n", file)
-			allRequestsOk = false
-			continue
-		}
-		req, _ = http.NewRequest("DELETE", string(deleteUrl), nil)
-		if resp, err = receiver.httpClient.Do(req); err != nil { // TODO: find out if Client.Do() does it in a goroutine
-			fmt.Printf("issuing request failed: %s\n", err)
-			allRequestsOk = false
-			continue
-		}
-		// if receiver.debug {
-		// 	resp.Write(os.Stdout)
-		// }
-		defer resp.Body.Close()
-		// body, _ := ioutil.ReadAll(resp.Body)
-		// if receiver.debug{
-		// 	fmt.Println(string(body))
-		// }
-		// TODO: assume here we got a 200 response code (what is 200 for transfer ?)
-		if resp.Status != "200 OK" {
-			fmt.Println("method not allowed (invalid url or file was deleted)")
-			allRequestsOk = false
-			continue
-		}
-		err = db.Update(func(tx *bolt.Tx) error {
-
-			bucket = tx.Bucket([]byte(receiver.dbBucketName))
-			err = bucket.Delete([]byte(file)) // we're confident that the key does exist, right ?
-
+	}
+	if verify {
+		if err = checkSHA256(fileUrl, hex.EncodeToString(hash.Sum(nil))); err != nil {
 			return err
-
-		})
-		if err != nil {
-			fmt.Printf("error deleting link %s from db\n", file)
-			allRequestsOk = false
-			continue
 		}
 	}
-	if allRequestsOk == false {
-		return fmt.Errorf("one or more files were not deleted")
+	if left := remaining(info); left != "" {
+		fmt.Fprintf(os.Stderr, "%s\n", left) // stdout is the file
 	}
-	fmt.Println("done")
 	return nil
 }
 
-var (
-	// ====== default values for options
-	transfer = transferSh{ // service transfer.sh
-		hostUrl:      serverProd,
-		maxDownloads: -1,
-		maxDays:      7,
-		httpClient:   &http.Client{},
-		dbName:       "sendall.db",  // bolt db name
-		dbBucketName: "transfer.sh", // bucket used within bolt; contains the posted urls -> deleted urls
-		debug:        true,
-	}
-	// ======
-	transferReqHeaders = []string{ // any custom headers used in issuing the request; for reference only
-		"Max-Downloads",
-		"Max-Days",
-	}
-	transferRespHeaders = []string{ // any custom headers received on response; for reference only
-		"X-Url-Delete",
-	}
-	transferShCmd = &cobra.Command{
-		Use:   "transfer",
-		Short: "use transfer.sh service (credits go to DutchCoders)",
-		Args:  cobra.MinimumNArgs(1),
-		Run: func(command *cobra.Command, args []string) {
-			// flags have populated cmd memebrs of the service struct
-			transfer.filePaths = prepareFiles(args) // files are provided straight from the cmd interface; tidy them
-			chanHttpResponses := make(chan *http.Response, len(transfer.filePaths))
-			chanExtraStrings := make(chan []string, 0) // we won't be sending any extra information for this service
-			go func() {
-				if err := transfer.Post(chanHttpResponses, chanExtraStrings); err != nil {
-					fmt.Println(err)
-				}
-			}()
-			if err := transfer.SaveUrl(chanHttpResponses, chanExtraStrings); err != nil {
-				fmt.Println(err)
-			}
-		},
+// finishDownload turns the complete .part file into name, decrypting it if the link has a key, and
+// returns the sha256 of the result. a .part file that fails to decrypt is kept, to try again with another key
+func finishDownload(part, name string, key []byte) (string, error) {
+	in, err := os.Open(part)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	hash := sha256.New()
+	if key == nil {
+		if _, err = io.Copy(hash, in); err != nil {
+			return "", err
+		}
+		in.Close()
+		return hex.EncodeToString(hash.Sum(nil)), os.Rename(part, name)
 	}
 
-	transferShDeleteCmd = &cobra.Command{
-		Use:   "delete",
-		Short: "delete a link posted before",
-		Args:  cobra.MinimumNArgs(1),
-		Run: func(cmd *cobra.Command, args []string) {
-			// flags have populated cmd memebrs of the "transfer" struct
-			transfer.filePaths = args // it is expected that provided arguments are the exact links you received from the service
-			if err := transfer.Delete(); err != nil {
-				fmt.Println(err)
-			}
-		},
+	plain, err := sendall.NewDecryptReader(bufio.NewReader(in), key)
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", part, err)
 	}
-)
+	out, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(io.MultiWriter(out, hash), plain)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(name) // never leave a half decrypted file behind
+		return "", fmt.Errorf("%s: %s", part, err)
+	}
+	in.Close()
+	return hex.EncodeToString(hash.Sum(nil)), os.Remove(part)
+}
 
-func init() {
-	transferShCmd.Flags().IntVarP(&transfer.maxDownloads, "downloads", "e", transfer.maxDownloads, "Maximum number of downloads after which the link will expire")
-	transferShCmd.Flags().IntVarP(&transfer.maxDays, "days", "d", transfer.maxDays, "Maximum number of days after which the file will be removed from the server")
-	transferShCmd.Flags().StringVarP(&transfer.hostUrl, "host", "u", transfer.hostUrl, "service URL, for example if you host your own instance")
-	transferShCmd.AddCommand(transferShDeleteCmd)
-	rootCmd.AddCommand(transferShCmd)
+func checkSHA256(fileUrl, sum string) error {
+	rec, err := sendall.NewStore(dbName).Get(fileUrl)
+	if err != nil {
+		return fmt.Errorf("can't verify: %s", err)
+	}
+	if rec.SHA256 == "" {
+		return fmt.Errorf("can't verify: no sha256 was recorded for %s (uploaded by an older sendall?)", fileUrl)
+	}
+	if rec.SHA256 != sum {
+		return fmt.Errorf("sha256 mismatch: uploaded %s, downloaded %s", rec.SHA256, sum)
+	}
+	return nil
 }
 
-// PUT: /put/$filename, /upload/$filename, /$filename
-// POST: /
-// DELETE: /$token/$filename/$deletiontoken		// provided by default by the server
-// GET: /$token/$filename
-// HEAD: /$token/$filename
+// remaining tells how many downloads and days the server has left for the file, if it said so
+func remaining(info sendall.DownloadInfo) string {
+	var left []string
+	if info.RemainingDownloads != "" {
+		left = append(left, "downloads left: "+info.RemainingDownloads)
+	}
+	if info.RemainingDays != "" {
+		left = append(left, "days left: "+info.RemainingDays)
+	}
+	return strings.Join(left, ", ")
+}
@@ -2,12 +2,35 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
+
+	"golang.org/x/crypto/ssh/terminal"
+	"rfc2119/sendall/sendall"
 )
 
-func sanitize(fileName string) string {
-	// didn't know about path.Clean()! credit goes to DutchCoders
-	return filepath.Clean(filepath.Base(fileName))
+const stdinName = "-" // the file name that stands for stdin
+
+// in pipe mode stdout only carries share urls (unless asked otherwise), so that "sendall transfer f | xclip" does what you'd expect
+var pipeMode = isTerminal(os.Stdout) == false
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// readSecret asks for a password on the terminal without echoing it; the terminal is opened directly,
+// since stdin may well be the file being uploaded
+func readSecret(prompt string) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("no terminal to ask for it on")
+	}
+	defer tty.Close()
+	fmt.Fprint(tty, prompt)
+	secret, err := terminal.ReadPassword(int(tty.Fd()))
+	fmt.Fprintln(tty)
+	return string(secret), err
 }
 
 func prepareFiles(files []string) []string {
@@ -22,3 +45,46 @@ func prepareFiles(files []string) []string {
 
 	return fileList
 }
+
+// prepareUploads turns the cmd arguments into uploads; "-" (or no argument at all while stdin is piped) reads stdin.
+// name, if given, is the remote file name of stdin, or of the only file given
+func prepareUploads(args []string, name string) ([]sendall.UploadRequest, error) {
+	if len(args) == 0 {
+		if isTerminal(os.Stdin) {
+			return nil, fmt.Errorf("nothing to upload; give a file, or pipe something into sendall")
+		}
+		args = []string{stdinName}
+	}
+
+	uploads := make([]sendall.UploadRequest, 0, len(args))
+	readsStdin := false
+	for _, file := range args {
+		if file != stdinName {
+			uploads = append(uploads, sendall.UploadRequest{Path: prepareFiles([]string{file})[0]})
+			continue
+		}
+		if readsStdin {
+			return nil, fmt.Errorf("stdin can only be uploaded once")
+		}
+		readsStdin = true
+		stdinUpload := sendall.UploadRequest{Path: stdinName, Name: name, Body: os.Stdin}
+		if stdinUpload.Name == "" {
+			stdinUpload.Name = "stdin"
+		}
+		uploads = append(uploads, stdinUpload)
+	}
+	if len(uploads) == 1 && name != "" {
+		uploads[0].Name = name
+	}
+	return uploads, nil
+}
+
+// archivesStdin tells whether stdin is among the uploads, which --archive can't take
+func archivesStdin(uploads []sendall.UploadRequest) bool {
+	for _, upload := range uploads {
+		if upload.Path == stdinName {
+			return true
+		}
+	}
+	return false
+}
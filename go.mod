@@ -3,9 +3,10 @@ module rfc2119/sendall
 go 1.14
 
 require (
-	github.com/boltdb/bolt v1.3.1
 	github.com/btcsuite/btcutil v1.0.2
 	github.com/gorilla/mux v1.7.4
 	github.com/spf13/cobra v1.0.0
+	go.etcd.io/bbolt v1.3.6
 	golang.org/x/crypto v0.0.0-20200115085410-6d4e4cb37c7d
+	gopkg.in/yaml.v2 v2.3.0
 )
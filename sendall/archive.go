@@ -0,0 +1,128 @@
+package sendall
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// archive formats understood by NewArchive; transfer.sh serves bundles in the same formats
+const (
+	ArchiveZip   = "zip"
+	ArchiveTarGz = "tar.gz"
+)
+
+// NewArchive returns an upload that streams paths (files or directories) into a single archive as it is read;
+// nothing is written to disk, and errors while archiving surface as read errors of the upload's Body
+func NewArchive(paths []string, format string) (UploadRequest, error) {
+	var write func(w io.Writer, paths []string) error
+	switch format {
+	case ArchiveZip:
+		write = writeZip
+	case ArchiveTarGz:
+		write = writeTarGz
+	default:
+		return UploadRequest{}, fmt.Errorf("unknown archive format %s; values: [%s, %s]", format, ArchiveZip, ArchiveTarGz)
+	}
+	if len(paths) == 0 {
+		return UploadRequest{}, fmt.Errorf("nothing to archive")
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			return UploadRequest{}, err
+		}
+	}
+
+	name := "archive." + format
+	if len(paths) == 1 {
+		name = sanitize(paths[0]) + "." + format
+	}
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(write(writer, paths))
+	}()
+	return UploadRequest{Path: strings.Join(paths, ","), Name: name, Body: reader}, nil
+}
+
+// walkFiles calls fn on every regular file under paths, along with its name inside the archive
+func walkFiles(paths []string, fn func(path, name string, info os.FileInfo) error) error {
+	for _, root := range paths {
+		parent := filepath.Dir(filepath.Clean(root)) // names start at the given file or directory
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.Mode().IsRegular() == false {
+				return nil
+			}
+			name, err := filepath.Rel(parent, path)
+			if err != nil {
+				return err
+			}
+			return fn(path, filepath.ToSlash(name), info)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(w, file)
+	return err
+}
+
+func writeZip(w io.Writer, paths []string) error {
+	archive := zip.NewWriter(w)
+	err := walkFiles(paths, func(path, name string, info os.FileInfo) error {
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		header.Method = zip.Deflate
+		entry, err := archive.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		return copyFile(entry, path)
+	})
+	if err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+func writeTarGz(w io.Writer, paths []string) error {
+	compressed := gzip.NewWriter(w)
+	archive := tar.NewWriter(compressed)
+	err := walkFiles(paths, func(path, name string, info os.FileInfo) error {
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		if err = archive.WriteHeader(header); err != nil {
+			return err
+		}
+		return copyFile(archive, path)
+	})
+	if err != nil {
+		return err
+	}
+	if err = archive.Close(); err != nil {
+		return err
+	}
+	return compressed.Close()
+}
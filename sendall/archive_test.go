@@ -0,0 +1,84 @@
+package sendall
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestNewArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sendall")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "logs", "old"), 0700)
+	ioutil.WriteFile(filepath.Join(dir, "logs", "a.log"), []byte("aaa"), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "logs", "old", "b.log"), []byte("bbb"), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("notes"), 0600)
+	paths := []string{filepath.Join(dir, "logs"), filepath.Join(dir, "notes.txt")}
+	expected := []string{"logs/a.log", "logs/old/b.log", "notes.txt"}
+
+	for _, format := range []string{ArchiveZip, ArchiveTarGz} {
+		upload, err := NewArchive(paths, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadAll(upload.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := []string{}
+		if format == ArchiveZip {
+			archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, file := range archive.File {
+				names = append(names, file.Name)
+			}
+		} else {
+			uncompressed, err := gzip.NewReader(bytes.NewReader(content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			archive := tar.NewReader(uncompressed)
+			for {
+				header, err := archive.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				names = append(names, header.Name)
+			}
+		}
+		sort.Strings(names)
+		if len(names) != len(expected) {
+			t.Fatalf("%s: expected %v, got %v", format, expected, names)
+		}
+		for i := range names {
+			if names[i] != expected[i] {
+				t.Errorf("%s: expected %v, got %v", format, expected, names)
+			}
+		}
+	}
+
+	if _, err = NewArchive([]string{filepath.Join(dir, "welp")}, ArchiveZip); err == nil {
+		t.Error("archived a file that does not exist")
+	}
+	if _, err = NewArchive(paths, "rar"); err == nil {
+		t.Error("accepted an unknown archive format")
+	}
+	if _, err = NewArchive(nil, ArchiveZip); err == nil {
+		t.Error("built an empty archive")
+	}
+}
@@ -0,0 +1,837 @@
+package sendall
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/pbkdf2"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/btcsuite/btcutil/base58"
+)
+
+const (
+	nonceSizeBytes  = 16 // privatebin uses a nonce of 16 bytes by default
+	aesKeySizeBytes = 32 // using aes-256-gcm; for reference only
+	gcmTagSize      = 16 // for reference
+	kdfSaltSize     = 8  // for reference
+	kdfIterations   = 100000
+)
+
+// Array1 : not used directly in the paste request
+type Array1 struct { // TODO: more descriptive name
+	Nonce           []byte // base64(cipher_iv); getRandomBytes(16) default
+	Kdfsalt         []byte // base64(kdf_salt); getRandomBytes(8) default
+	KdfIterations   int    // pbkdf_iterations; default
+	KdfKeySize      int    // pbkdf_keysize; default
+	CipherTagSize   int    // cipher_tag_size; default
+	CipherAlgo      string // cipher_algo; default
+	CipherMode      string // cipher_mode; default
+	CompressionType string // compression_type; default
+}
+
+// AuthData : Format is the paste's format.
+type AuthData struct {
+	EncryptionDetails []interface{}
+	Format            string // format of the paste
+	OpenDiscussion    int    // open-discussion flag
+	BurnAfterReading  int    // burn-after-reading flag
+}
+
+// PasteData : !shrug (see https://github.com/PrivateBin/PrivateBin/wiki/Encryption-format#data-passed-in)
+type PasteData struct {
+	Paste           string        `json:"paste"`                     // the text of the paste; a note when there is an attachment
+	Attachment      string        `json:"attachment,omitempty"`      // data:<mime>;base64,<data>
+	AttachementName string        `json:"attachment_name,omitempty"` // privatebin shows an attachment whenever these keys exist
+	Children        []interface{} `json:"children,omitempty"`
+	Language        string        `json:"language,omitempty"` // not read by privatebin, which guesses the language; kept for other clients
+}
+
+// PasteMeta : https://raw.githubusercontent.com/PrivateBin/PrivateBin/master/js/types.jsonld
+type PasteMeta struct {
+	Expire string `json:"expire"` // ["5min", "10min", "1hour", "1day", "1week", "1month", "1year", "never"]
+}
+
+// lifetimes of the expire values above, as configured on a stock instance
+var pasteLifetimes = map[string]time.Duration{
+	"5min":   5 * time.Minute,
+	"10min":  10 * time.Minute,
+	"1hour":  time.Hour,
+	"1day":   24 * time.Hour,
+	"1week":  7 * 24 * time.Hour,
+	"1month": 30 * 24 * time.Hour,
+	"1year":  365 * 24 * time.Hour,
+	"never":  0,
+}
+
+// PasteResponse : A request's response, parsed
+type PasteResponse struct {
+	Status      int    `json:"status"`
+	Message     string `json:"message"` // set when status is not 0
+	Id          string `json:"id"`
+	Url         string `json:"url"`
+	Deletetoken string `json:"deletetoken"`
+}
+
+// PasteRequest : A paste request (TODO: keep struct local and apply NewPasteRequest as a method)
+type PasteRequest struct {
+	AuthData   []interface{} `json:"adata"`
+	Meta       PasteMeta     `json:"meta"`
+	Version    int           `json:"v"`
+	CipherText []byte        `json:"ct"`
+}
+
+// NewPasteRequest : Forges a new request to be posted.
+func NewPasteRequest(aData []interface{}, cipherText []byte, expiryDate string) *PasteRequest {
+
+	var (
+		req PasteRequest
+	)
+
+	meta := PasteMeta{expiryDate}
+
+	req.AuthData = aData
+	req.Meta = meta
+	req.Version = 2 // constant; defined by private bin API
+	req.CipherText = cipherText
+	return &req
+
+}
+
+// PrivateBinOptions : options of the privatebin service
+type PrivateBinOptions struct {
+	Host             string // service URL, for example if you host your own instance
+	Expire           string // one of the PasteMeta expire values
+	Format           string // format of the paste; [plaintext, syntaxhighlighting, markdown] on a stock instance
+	Language         string // language of a syntaxhighlighting paste, e.g. go
+	OpenDiscussion   int    // opens paste for discussion
+	BurnAfterReading int    // invalidates paste after one access
+	Password         string // mixed into the key; readers of the paste are asked for it
+	Compression      string // compression of the paste before encryption; [zlib, none]
+	Attach           bool   // post files as attachments (as the web ui does) rather than as text
+	Text             string // text shown along with an attachment
+	APIVersion       string // [auto, 1, 2]; 1 for privatebin 1.0-1.2 and zerobin, auto asks the instance
+}
+
+// DefaultPrivateBinOptions returns the options used when no host is given
+func DefaultPrivateBinOptions() PrivateBinOptions {
+	return PrivateBinOptions{
+		Host:             "https://bin.fraq.io",
+		Expire:           "1week",
+		Format:           "plaintext",
+		OpenDiscussion:   0,
+		BurnAfterReading: 0,
+		Compression:      "zlib",
+		APIVersion:       "auto",
+	}
+}
+
+// PrivateBin : the privatebin service; pastes are encrypted before leaving the machine
+type PrivateBin struct {
+	Options    PrivateBinOptions
+	HTTPClient *http.Client
+	// PasswordPrompt, if set, is asked for a password when Get can't open a paste without one
+	PasswordPrompt func() (string, error)
+
+	instances sync.Map // host -> instanceConfig
+}
+
+// ErrBadKey : the paste did not open with the key (and password) given
+var ErrBadKey = errors.New("could not decrypt the paste (wrong key or password?)")
+
+// NewPrivateBin returns a privatebin service using the default http client, retrying by DefaultRetryPolicy()
+func NewPrivateBin(options PrivateBinOptions) *PrivateBin {
+	return &PrivateBin{Options: options, HTTPClient: retryingClient(&http.Client{}, DefaultRetryPolicy())}
+}
+
+// SetRetryPolicy : requests failing for a transient reason are retried by policy from now on
+func (pbinReciever *PrivateBin) SetRetryPolicy(policy RetryPolicy) {
+	pbinReciever.HTTPClient = retryingClient(pbinReciever.HTTPClient, policy)
+}
+
+func init() {
+	defaults := DefaultPrivateBinOptions()
+	Register(Backend{
+		Name:        "privatebin",
+		StoreName:   "privateBin",
+		Description: "use privatebin to post your text files safely",
+		Options: []Option{
+			{Name: "days", Shorthand: "d", Default: defaults.Expire, Usage: "Maximum number of days after which the file will be removed from the server" +
+				"\nvalues:  [5min, 10min, 1hour, 1day, 1week, 1month, 1year, never], or what the instance offers"},
+			{Name: "host", Shorthand: "u", Default: defaults.Host, Usage: "service URL, for example if you host your own instance"},
+			{Name: "format", Shorthand: "f", Default: defaults.Format, Usage: "format of the paste; values: [plaintext, syntaxhighlighting, markdown], or what the instance offers"},
+			{Name: "language", Default: "", Usage: "language of a syntaxhighlighting paste, e.g. go; a hint for clients, privatebin guesses it itself"},
+			{Name: "open-discussion", Shorthand: "o", Default: defaults.OpenDiscussion, Usage: "opens paste for discussion; see \"privatebin comment\""},
+			{Name: "burn-after-reading", Shorthand: "b", Default: defaults.BurnAfterReading, Usage: "invalidates paste after one access"},
+			{Name: "compression", Default: defaults.Compression, Usage: "compress the paste before encrypting it; values: [zlib, none]"},
+			{Name: "attach", Default: false, Usage: "post the files as attachments (screenshots, binaries) rather than as text"},
+			{Name: "text", Default: "", Usage: "text shown along with an attachment"},
+			{Name: "api-version", Default: defaults.APIVersion, Usage: "api of the instance: 2 for privatebin 1.3+, 1 for older ones and zerobin; values: [auto, 1, 2]"},
+			{Name: "password", Default: "", Secret: true, Usage: "protect the paste with a password; \"-\" asks for it"},
+			{Name: "password-file", Default: "", Usage: "read the password from this file"},
+		},
+		Capabilities: Capabilities{Delete: true, Download: true, Encryption: true},
+		New: func(values Values) (Service, error) {
+			password := values.String("password")
+			if path := values.String("password-file"); path != "" {
+				content, err := ioutil.ReadFile(path)
+				if err != nil {
+					return nil, fmt.Errorf("password file: %s", err)
+				}
+				password = strings.TrimRight(string(content), "\r\n") // files usually end with a newline
+			}
+			return NewPrivateBin(PrivateBinOptions{
+				Host:             values.String("host"),
+				Expire:           values.String("days"),
+				Format:           values.String("format"),
+				OpenDiscussion:   values.Int("open-discussion"),
+				BurnAfterReading: values.Int("burn-after-reading"),
+				Password:         password,
+				Compression:      values.String("compression"),
+				Attach:           values.Bool("attach"),
+				Text:             values.String("text"),
+				APIVersion:       values.String("api-version"),
+				Language:         values.String("language"),
+			}), nil
+		},
+	})
+}
+
+func (pbinReciever *PrivateBin) Name() string {
+	return "privateBin"
+}
+
+// Delete asks the instance to remove the paste, the way privatebin's own delete link does, through the json api;
+// pastes that are gone already give ErrGone
+func (pbinReciever *PrivateBin) Delete(ctx context.Context, rec Record) error {
+
+	var (
+		parsedResponse PasteResponse
+		deleteReq      interface{}
+		resp           *http.Response
+	)
+	deleteUrl, err := url.Parse(rec.DeleteURL) // host/?pasteid=ID&deletetoken=TOKEN
+	if err != nil {
+		return err
+	}
+	query := deleteUrl.Query()
+	if query.Get("pasteid") == "" || query.Get("deletetoken") == "" {
+		return fmt.Errorf("%s is not a delete url; expected https://host/?pasteid=ID&deletetoken=TOKEN", rec.DeleteURL)
+	}
+	host := strings.TrimSuffix(deleteUrl.Scheme+"://"+deleteUrl.Host+deleteUrl.Path, "/")
+	version, err := pbinReciever.apiVersion(ctx, host)
+	if err != nil {
+		return err
+	}
+	deleteReq = map[string]string{"pasteid": query.Get("pasteid"), "deletetoken": query.Get("deletetoken")}
+	if version == 1 {
+		deleteReq = url.Values{"pasteid": {query.Get("pasteid")}, "deletetoken": {query.Get("deletetoken")}}
+	}
+	if resp, err = pbinReciever.sendPaste(ctx, host, deleteReq); err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err = json.NewDecoder(resp.Body).Decode(&parsedResponse); err != nil {
+		return fmt.Errorf("json decoding error: %s (is %s a privatebin instance?)", err, host)
+	}
+	if parsedResponse.Status != 0 {
+		// "Paste does not exist, has expired or has been deleted."
+		if strings.Contains(parsedResponse.Message, "does not exist") {
+			return ErrGone
+		}
+		return fmt.Errorf("server refused: %s", parsedResponse.Message)
+	}
+	return nil
+}
+
+func (pbinReciever *PrivateBin) Upload(ctx context.Context, upload UploadRequest) (UploadResult, error) {
+
+	var (
+		parsedResponse PasteResponse
+		pasteReq       interface{}
+		resp           *http.Response
+		err            error
+		plaintext      []byte
+	)
+	result := UploadResult{Service: pbinReciever.Name(), File: upload.Path}
+	if err = pbinReciever.checkOptions(ctx, pbinReciever.Options.Host); err != nil {
+		return result, err
+	}
+	body := upload.Body
+	if body == nil {
+		file, err := os.Open(upload.Path)
+		if err != nil {
+			return result, fmt.Errorf("read file error: %s", err)
+		}
+		defer file.Close()
+		body = file
+	}
+	// pastes are encrypted as a whole, so progress only follows the reading of the file
+	if plaintext, err = ioutil.ReadAll(uploadCounter(body, upload)); err != nil {
+		return result, fmt.Errorf("read file error: %s", err)
+	}
+	result.Size = int64(len(plaintext))
+	pasteData := PasteData{Paste: string(plaintext), Language: pbinReciever.Options.Language}
+	// json turns invalid utf-8 into U+FFFD, so binary files (e.g. an --archive) only survive as attachments
+	if pbinReciever.Options.Attach || utf8.Valid(plaintext) == false {
+		name := upload.Name
+		if name == "" {
+			name = filepath.Base(upload.Path)
+		}
+		pasteData = PasteData{Paste: pbinReciever.Options.Text, Attachment: dataUri(name, plaintext), AttachementName: name, Language: pbinReciever.Options.Language}
+	}
+	version, err := pbinReciever.apiVersion(ctx, pbinReciever.Options.Host)
+	if err != nil {
+		return result, err
+	}
+	keyPrefix := ""
+	if version == 1 {
+		if pasteReq, result.Key, err = pbinReciever.pasteFormV1(pasteData); err != nil {
+			return result, err
+		}
+	} else {
+		key, nonce, kdfsalt, err := generateEncryptionParameters()
+		if err != nil {
+			return result, err
+		}
+		adata := generateAuthenticationData(nonce, kdfsalt, pbinReciever.Options.Format, pbinReciever.Options.OpenDiscussion, pbinReciever.Options.BurnAfterReading, pbinReciever.Options.Compression)
+		aesKey := pbkdf2.Key(keyMaterial(key, pbinReciever.Options.Password), kdfsalt, kdfIterations, aesKeySizeBytes, sha256.New)
+		ciphertext, err := encrypt(pasteData, aesKey, nonce, adata) // auth tag is appended to ciphertext
+		if err != nil {
+			return result, err
+		}
+		pasteReq = NewPasteRequest(adata, ciphertext, pbinReciever.Options.Expire)
+		// we neeed the key to construct the url
+		result.Key = base58.Encode(key)
+		if config, _ := pbinReciever.instance(ctx, pbinReciever.Options.Host); config.burnPrefix && pbinReciever.Options.BurnAfterReading != 0 {
+			keyPrefix = "-" // like privatebin 1.6+ does: whoever opens the link is asked before the paste burns
+		}
+	}
+	if resp, err = pbinReciever.sendPaste(ctx, pbinReciever.Options.Host, pasteReq); err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+	if err = json.NewDecoder(resp.Body).Decode(&parsedResponse); err != nil {
+		return result, fmt.Errorf("json decoding error: %s", err)
+	}
+	if parsedResponse.Status != 0 {
+		return result, fmt.Errorf("server refused: %s", parsedResponse.Message)
+	}
+
+	if parsedResponse.Url == "" { // zerobin only hands out the id
+		parsedResponse.Url = "/?" + parsedResponse.Id
+	}
+	result.URL = fmt.Sprintf("%s%s#%s%s", pbinReciever.Options.Host, parsedResponse.Url, keyPrefix, result.Key)
+	result.DeleteURL = fmt.Sprintf("%s/?pasteid=%s&deletetoken=%s", pbinReciever.Options.Host, parsedResponse.Id, parsedResponse.Deletetoken)
+	if lifetime, ok := pasteLifetimes[pbinReciever.Options.Expire]; ok && lifetime > 0 {
+		result.ExpiresAt = time.Now().Add(lifetime)
+	}
+	if pbinReciever.Options.BurnAfterReading != 0 {
+		result.MaxDownloads = 1
+	}
+	return result, nil
+}
+
+// keyMaterial is what goes into pbkdf2: the random key followed by the utf-8 bytes of the password, as privatebin's js does
+func keyMaterial(key []byte, password string) []byte {
+	material := make([]byte, 0, len(key)+len(password))
+	return append(append(material, key...), password...)
+}
+
+func generateAuthenticationData(iv []byte, dummyKDFsalt []byte, format string, openDiscussion int, burnAfterReading int, compression string) []interface{} {
+	// encryptionInfo := Array1{iv, dummyKDFsalt, 10000, 265, 128, "aes", "gcm", "zlib"}
+	// encryptionInfo := make([]interface{}, 0)
+	// aData := make([]interface{}, 0); then append
+	var (
+		encryptionInfo, aData []interface{}
+	)
+	encryptionInfo = append(encryptionInfo, iv, dummyKDFsalt, kdfIterations, aesKeySizeBytes*8, nonceSizeBytes*8, "aes", "gcm", compression)
+	aData = append(aData, encryptionInfo, format, openDiscussion, burnAfterReading)
+	return aData
+}
+
+func generateEncryptionParameters() (key, iv, kdfSalt []byte, err error) {
+
+	// since we'll be using a different random key for each paste,
+	// a fixed nonce should be OK (but we won't do it anyway)
+	totalSize := aesKeySizeBytes + nonceSizeBytes + kdfSaltSize
+	keyWithNonceAndKdfSalt := make([]byte, totalSize)
+	if _, err = io.ReadFull(rand.Reader, keyWithNonceAndKdfSalt); err != nil {
+		return nil, nil, nil, fmt.Errorf("unable to generate a key: %s", err)
+	}
+
+	key = keyWithNonceAndKdfSalt[:aesKeySizeBytes]
+	iv = keyWithNonceAndKdfSalt[aesKeySizeBytes : aesKeySizeBytes+nonceSizeBytes]
+	kdfSalt = keyWithNonceAndKdfSalt[totalSize-kdfSaltSize:]
+
+	return key, iv, kdfSalt, nil
+
+}
+
+func (pbinReciever *PrivateBin) sendPaste(ctx context.Context, host string, pasteReq interface{}) (*http.Response, error) {
+	// marshals data (a paste or a comment; a form for v1), sends a new request and returns the received response
+	var (
+		pasteReqJson []byte
+		req          *http.Request
+		err          error
+	)
+
+	if form, ok := pasteReq.(url.Values); ok {
+		pasteReqJson = []byte(form.Encode())
+	} else if pasteReqJson, err = json.Marshal(pasteReq); err != nil { // Marshal, not NewEncoder
+		return nil, fmt.Errorf("unable to marshal req: %s", err)
+	}
+	// ==== cert ==== //
+	//  self-signed certificates workaround (https://groups.google.com/d/msg/golang-nuts/v5ShM8R7Tdc/I2wyTy1o118J)
+	// ==== end cert ///
+	if req, err = http.NewRequestWithContext(ctx, "POST", host, bytes.NewReader(pasteReqJson)); err != nil {
+		return nil, fmt.Errorf("failed to generate a request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=UTF-8")
+	req.Header.Add("X-Requested-With", "JSONHttpRequest") // reason we used http.NewRequest w/ Client.Do()
+
+	// debugging sent request as seen in a proxy; remmeber that the request is consumed if you used WriteProxy, so you can't re-use it later
+	// url, err := http.ProxyFromEnvironment(req)
+	// fmt.Printf("proxy: %s %s\n", url, err)
+	// req.WriteProxy(os.Stdout)
+
+	resp, err := pbinReciever.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("post to paste site error: %s", err) // TODO: use log
+	}
+	return resp, nil
+}
+
+func encrypt(message interface{}, key, iv []byte, authenticationData []interface{}) (ciphertext []byte, err error) {
+	// compresses the message (a PasteData or CommentData) as the adata says and encrypts it with a random key
+
+	block, err := aes.NewCipher(key) // will auto-pick aes-256 because of key size
+	if err != nil {
+		return nil, fmt.Errorf("unable to set up aes: %s", err)
+	}
+
+	aesgcm, err := cipher.NewGCMWithNonceSize(block, nonceSizeBytes) //TODO: should instruct privatebin to use standard nonce size instead
+	if err != nil {
+		return nil, fmt.Errorf("unable to set up gcm: %s", err)
+	}
+
+	// compress and encrypt message, then encode key and return
+	var (
+		// pasteData       PasteData
+		// encodedCompressedPlaintext bytes.Buffer
+		cipherJson, authenticatedDataJson []byte
+	)
+	if cipherJson, err = json.Marshal(message); err != nil { // Marshal, not NewEncoder
+		return nil, fmt.Errorf("unable to marshal message: %s", err)
+	}
+	if authenticatedDataJson, err = json.Marshal(authenticationData); err != nil { // Marshal, not NewEncoder
+		return nil, fmt.Errorf("unable to marshal adata: %s", err)
+	}
+	// fmt.Printf("marshalled cipher: %s\n", cipherJson) // TODO: output this on debug flag
+	// fmt.Printf("marshalled adata: %s\n", authenticatedDataJson) // TODO: output this on debug flag
+
+	// pastes nest the encryption info in their adata, comments use it as their adata
+	encryptionInfo, ok := authenticationData[0].([]interface{})
+	if ok == false {
+		encryptionInfo = authenticationData
+	}
+	if cipherJson, err = compress(cipherJson, encryptionInfo[7].(string)); err != nil {
+		return nil, err
+	}
+	// encoder := base64.NewEncoder(base64.StdEncoding, &encodedCompressedPlaintext)
+	// encoder.Write(compressedCiphertext.Bytes())
+	// encoder.Close()
+
+	// authData is authenticated as well(https://github.com/r4sas/PBinCLI/blob/682b47fbd3e24a8a53c3b484ba896a5dbc85cda2/pbincli/format.py#L122)
+	// kudos to filo for hinting about the tag location (https://github.com/golang/go/issues/32742)
+	// look for function " decryptOrPromptPassword" in privatebin.js; start debugging there
+	// TODO: fully support the API (https://github.com/PrivateBin/PrivateBin/wiki/API)
+	ciphertext = aesgcm.Seal(nil, iv, cipherJson, authenticatedDataJson)
+	// 	encodedNonce := base64.StdEncoding.EncodeToString(nonce)
+	// 	encodedCipherText := base64.StdEncoding.EncodeToString(ciphertext)
+	// fmt.Printf("pt: %s\n key: %s\n", plaintext, base58.Encode(key)) // TODO: output this on debug flag
+	return ciphertext, nil
+}
+
+// compress applies the compression_type of the adata; privatebin's "zlib" is raw deflate, without the zlib header
+func compress(data []byte, compression string) ([]byte, error) {
+	if compression == "none" {
+		return data, nil
+	}
+	var compressed bytes.Buffer
+	writer, err := flate.NewWriter(&compressed, flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+	return compressed.Bytes(), nil
+}
+
+// PasteGetResponse : the server's answer to a JSON request of a paste
+type PasteGetResponse struct {
+	Status     int               `json:"status"`
+	Message    string            `json:"message"` // set when status is not 0
+	Id         string            `json:"id"`
+	AuthData   []interface{}     `json:"adata"`
+	Meta       PasteMeta         `json:"meta"`
+	Version    int               `json:"v"`
+	CipherText []byte            `json:"ct"`
+	Comments   []CommentResponse `json:"comments"`
+}
+
+// CommentResponse : one comment of the discussion of a paste, as the server hands it out
+type CommentResponse struct {
+	Id         string        `json:"id"`
+	ParentId   string        `json:"parentid"` // the paste id for top-level comments
+	AuthData   []interface{} `json:"adata"`    // comments only carry the encryption info
+	CipherText []byte        `json:"ct"`
+	Meta       struct {
+		Created int64 `json:"created"` // unix time
+	} `json:"meta"`
+}
+
+// CommentRequest : a comment to be posted to the discussion of a paste
+type CommentRequest struct {
+	AuthData   []interface{} `json:"adata"`
+	Version    int           `json:"v"`
+	CipherText []byte        `json:"ct"`
+	PasteId    string        `json:"pasteid"`
+	ParentId   string        `json:"parentid"`
+}
+
+// CommentData : what gets encrypted into a comment
+type CommentData struct {
+	Comment  string `json:"comment"`
+	Nickname string `json:"nickname,omitempty"`
+}
+
+// Paste : a paste fetched from privatebin, decrypted
+type Paste struct {
+	Id               string
+	Text             string
+	Attachment       []byte // nil if the paste has none
+	AttachmentName   string
+	AttachmentType   string // mime type of the attachment
+	Format           string
+	Language         string // hint of a syntaxhighlighting paste, if its poster gave one
+	OpenDiscussion   int
+	BurnAfterReading int
+	Comments         []Comment // in the order the server keeps them (oldest first)
+}
+
+// Comment : a comment of the discussion of a paste, decrypted
+type Comment struct {
+	Id       string
+	ParentId string // the paste id for top-level comments, the comment replied to otherwise
+	Nickname string // empty when posted anonymously
+	Text     string
+	Created  time.Time
+}
+
+// Get fetches the paste at pasteUrl (the url handed out on upload, key fragment included) and decrypts it;
+// the host is taken from the url, so pastes of any instance (and of either api version) can be read
+func (pbinReciever *PrivateBin) Get(ctx context.Context, pasteUrl string) (Paste, error) {
+	var (
+		paste    Paste
+		response struct {
+			Status  int    `json:"status"`
+			Message string `json:"message"` // set when status is not 0
+			Version int    `json:"v"`       // missing from v1 pastes
+		}
+		req  *http.Request
+		resp *http.Response
+		body []byte
+		err  error
+	)
+	host, pasteId, key, err := parsePasteUrl(pasteUrl)
+	if err != nil {
+		return paste, err
+	}
+	if req, err = http.NewRequestWithContext(ctx, "GET", host+"/?pasteid="+pasteId, nil); err != nil {
+		return paste, err
+	}
+	req.Header.Add("X-Requested-With", "JSONHttpRequest")
+	if resp, err = pbinReciever.HTTPClient.Do(req); err != nil {
+		return paste, fmt.Errorf("issuing request failed: %s", err)
+	}
+	defer resp.Body.Close()
+	if body, err = ioutil.ReadAll(resp.Body); err != nil {
+		return paste, fmt.Errorf("issuing request failed: %s", err)
+	}
+	if err = json.Unmarshal(body, &response); err != nil {
+		return paste, fmt.Errorf("json decoding error: %s", err)
+	}
+	if response.Status != 0 {
+		return paste, fmt.Errorf("server refused: %s", response.Message)
+	}
+
+	open := openPaste
+	if response.Version < 2 {
+		open = openPasteV1
+	}
+	password := pbinReciever.Options.Password
+	paste, err = open(body, key, password)
+	if err == ErrBadKey && password == "" && pbinReciever.PasswordPrompt != nil {
+		// nothing tells a password protected paste apart; the browser asks once decryption fails too
+		if password, err = pbinReciever.PasswordPrompt(); err != nil {
+			return paste, err
+		}
+		paste, err = open(body, key, password)
+	}
+	return paste, err
+}
+
+// openPaste decrypts a v2 paste, as fetched by Get()
+func openPaste(body []byte, key, password string) (Paste, error) {
+	var (
+		paste    Paste
+		response PasteGetResponse
+		err      error
+	)
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber() // adata is authenticated as is; numbers must survive a round trip untouched
+	if err = decoder.Decode(&response); err != nil {
+		return paste, fmt.Errorf("json decoding error: %s", err)
+	}
+	rawKey := base58.Decode(key)
+	if len(rawKey) == 0 {
+		return paste, fmt.Errorf("bad key %s", key)
+	}
+	if len(response.AuthData) == 0 {
+		return paste, fmt.Errorf("bad paste: no adata")
+	}
+	plaintext, err := decrypt(response.CipherText, response.AuthData[0], response.AuthData, keyMaterial(rawKey, password))
+	if err != nil {
+		return paste, err
+	}
+	var pasteData PasteData
+	if err = json.Unmarshal(plaintext, &pasteData); err != nil {
+		return paste, fmt.Errorf("bad paste: %s", err)
+	}
+	paste = Paste{Id: response.Id, Text: pasteData.Paste, AttachmentName: pasteData.AttachementName, Language: pasteData.Language}
+	if len(response.AuthData) >= 4 {
+		paste.Format, _ = response.AuthData[1].(string)
+		paste.OpenDiscussion = jsonInt(response.AuthData[2])
+		paste.BurnAfterReading = jsonInt(response.AuthData[3])
+	}
+	if pasteData.Attachment != "" {
+		if paste.AttachmentType, paste.Attachment, err = parseDataUri(pasteData.Attachment); err != nil {
+			return paste, err
+		}
+	}
+	// comments are encrypted with the key (and password) of the paste
+	for _, response := range response.Comments {
+		plaintext, err := decrypt(response.CipherText, response.AuthData, response.AuthData, keyMaterial(rawKey, password))
+		if err != nil {
+			return paste, fmt.Errorf("comment %s: %s", response.Id, err)
+		}
+		var commentData CommentData
+		if err = json.Unmarshal(plaintext, &commentData); err != nil {
+			return paste, fmt.Errorf("bad comment %s: %s", response.Id, err)
+		}
+		paste.Comments = append(paste.Comments, Comment{Id: response.Id, ParentId: response.ParentId, Nickname: commentData.Nickname,
+			Text: commentData.Comment, Created: time.Unix(response.Meta.Created, 0)})
+	}
+	return paste, nil
+}
+
+// Comment posts text to the discussion of the paste at pasteUrl, as a reply to the comment parentId
+// (or to the paste itself when parentId is empty), and returns the id of the new comment;
+// the paste must have been posted with open-discussion, and Options.Password must be the paste's
+func (pbinReciever *PrivateBin) Comment(ctx context.Context, pasteUrl, nickname, text, parentId string) (string, error) {
+	var (
+		parsedResponse PasteResponse
+		commentReq     interface{}
+		resp           *http.Response
+	)
+	host, pasteId, key, err := parsePasteUrl(pasteUrl)
+	if err != nil {
+		return "", err
+	}
+	if parentId == "" {
+		parentId = pasteId
+	}
+	version, err := pbinReciever.apiVersion(ctx, host)
+	if err != nil {
+		return "", err
+	}
+	if version == 1 {
+		if commentReq, err = pbinReciever.commentFormV1(key, pasteId, parentId, nickname, text); err != nil {
+			return "", err
+		}
+	} else {
+		compression := pbinReciever.Options.Compression
+		if compression != "zlib" && compression != "none" {
+			return "", fmt.Errorf("unknown compression %s; values: [zlib, none]", compression)
+		}
+		rawKey := base58.Decode(key)
+		if len(rawKey) == 0 {
+			return "", fmt.Errorf("bad key %s", key)
+		}
+		_, nonce, kdfsalt, err := generateEncryptionParameters()
+		if err != nil {
+			return "", err
+		}
+		// unlike pastes, the adata of a comment is the encryption info alone
+		adata := generateAuthenticationData(nonce, kdfsalt, "", 0, 0, compression)[0].([]interface{})
+		aesKey := pbkdf2.Key(keyMaterial(rawKey, pbinReciever.Options.Password), kdfsalt, kdfIterations, aesKeySizeBytes, sha256.New)
+		ciphertext, err := encrypt(CommentData{Comment: text, Nickname: nickname}, aesKey, nonce, adata)
+		if err != nil {
+			return "", err
+		}
+		commentReq = CommentRequest{AuthData: adata, Version: 2, CipherText: ciphertext, PasteId: pasteId, ParentId: parentId}
+	}
+	if resp, err = pbinReciever.sendPaste(ctx, host, commentReq); err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if err = json.NewDecoder(resp.Body).Decode(&parsedResponse); err != nil {
+		return "", fmt.Errorf("json decoding error: %s", err)
+	}
+	if parsedResponse.Status != 0 {
+		return "", fmt.Errorf("server refused: %s", parsedResponse.Message)
+	}
+	return parsedResponse.Id, nil
+}
+
+// BurnsAfterReading tells whether the link of a paste says it burns after reading: privatebin 1.6 and later
+// prefix the key of such pastes with "-", so that nothing is fetched (and burned) before the reader confirms
+func BurnsAfterReading(pasteUrl string) bool {
+	parsed, err := url.Parse(pasteUrl)
+	return err == nil && strings.HasPrefix(parsed.Fragment, "-")
+}
+
+// parsePasteUrl splits https://host/path/?pasteid#key into its parts; the key is left encoded,
+// as v1 pastes keep it in base64 and v2 ones in base58
+func parsePasteUrl(pasteUrl string) (host, pasteId, key string, err error) {
+	parsed, err := url.Parse(pasteUrl)
+	if err != nil {
+		return "", "", "", err
+	}
+	pasteId = parsed.RawQuery
+	if values, err := url.ParseQuery(parsed.RawQuery); err == nil && values.Get("pasteid") != "" {
+		pasteId = values.Get("pasteid")
+	}
+	// newer instances prefix the key with "-" to show a "load paste?" button first
+	key = strings.TrimPrefix(parsed.Fragment, "-")
+	if pasteId == "" || key == "" {
+		return "", "", "", fmt.Errorf("%s is not a paste url; expected https://host/?pasteid#key", pasteUrl)
+	}
+	host = strings.TrimSuffix(parsed.Scheme+"://"+parsed.Host+parsed.Path, "/")
+	return host, pasteId, key, nil
+}
+
+// dataUri embeds a file the way privatebin's web ui does; the mime type is sniffed from the content,
+// falling back to the file extension when the content says nothing
+func dataUri(name string, data []byte) string {
+	mimeType, _, _ := mime.ParseMediaType(http.DetectContentType(data)) // drops "; charset=utf-8"
+	if byExtension := mime.TypeByExtension(filepath.Ext(name)); mimeType == "application/octet-stream" && byExtension != "" {
+		mimeType, _, _ = mime.ParseMediaType(byExtension)
+	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// parseDataUri decodes data:<mime>;base64,<data>, the way privatebin embeds attachments
+func parseDataUri(uri string) (mimeType string, data []byte, err error) {
+	header := strings.SplitN(strings.TrimPrefix(uri, "data:"), ",", 2)
+	if strings.HasPrefix(uri, "data:") == false || len(header) != 2 || strings.HasSuffix(header[0], ";base64") == false {
+		return "", nil, fmt.Errorf("attachment is not a base64 data uri")
+	}
+	data, err = base64.StdEncoding.DecodeString(header[1])
+	return strings.TrimSuffix(header[0], ";base64"), data, err
+}
+
+func jsonInt(value interface{}) int {
+	switch number := value.(type) {
+	case json.Number:
+		n, _ := number.Int64()
+		return int(n)
+	case float64:
+		return int(number)
+	case int:
+		return number
+	}
+	return 0
+}
+
+func jsonBytes(value interface{}) ([]byte, error) {
+	text, ok := value.(string)
+	if ok == false {
+		return nil, fmt.Errorf("expected a base64 string, got %v", value)
+	}
+	return base64.StdEncoding.DecodeString(text)
+}
+
+// decrypt reverses encrypt(): derives the aes key from key (see keyMaterial) and the kdf parameters in encryptionInfo,
+// opens the ciphertext with authenticationData as aad and inflates it if it was compressed
+func decrypt(ciphertext []byte, encryptionInfo interface{}, authenticationData []interface{}, key []byte) ([]byte, error) {
+	// [iv, kdf_salt, kdf_iterations, kdf_keysize, cipher_tag_size, cipher_algo, cipher_mode, compression_type]
+	info, ok := encryptionInfo.([]interface{})
+	if ok == false || len(info) != 8 {
+		return nil, fmt.Errorf("unsupported paste format (v1 paste?)")
+	}
+	if info[5] != "aes" || info[6] != "gcm" || jsonInt(info[4]) != gcmTagSize*8 {
+		return nil, fmt.Errorf("unsupported cipher %v-%v with a %d bits tag", info[5], info[6], jsonInt(info[4]))
+	}
+	iv, err := jsonBytes(info[0])
+	if err != nil {
+		return nil, err
+	}
+	kdfSalt, err := jsonBytes(info[1])
+	if err != nil {
+		return nil, err
+	}
+	aesKey := pbkdf2.Key(key, kdfSalt, jsonInt(info[2]), jsonInt(info[3])/8, sha256.New)
+
+	block, err := aes.NewCipher(aesKey)
+	if err != nil {
+		return nil, err
+	}
+	aesgcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return nil, err
+	}
+	// the aad is the adata exactly as the browser's JSON.stringify() wrote it
+	var authenticatedDataJson bytes.Buffer
+	encoder := json.NewEncoder(&authenticatedDataJson)
+	encoder.SetEscapeHTML(false)
+	if err = encoder.Encode(authenticationData); err != nil {
+		return nil, err
+	}
+	plaintext, err := aesgcm.Open(nil, iv, ciphertext, bytes.TrimSuffix(authenticatedDataJson.Bytes(), []byte("\n")))
+	if err != nil {
+		return nil, ErrBadKey
+	}
+
+	switch info[7] {
+	case "none":
+		return plaintext, nil
+	case "zlib": // raw deflate, despite the name
+		return ioutil.ReadAll(flate.NewReader(bytes.NewReader(plaintext)))
+	default:
+		return nil, fmt.Errorf("unsupported compression %v", info[7])
+	}
+}
@@ -0,0 +1,134 @@
+package sendall
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// what a stock instance offers; used when the instance's page lists nothing
+var (
+	pasteExpiries = []string{"5min", "10min", "1hour", "1day", "1week", "1month", "1year", "never"}
+	pasteFormats  = []string{"plaintext", "syntaxhighlighting", "markdown"}
+)
+
+// instanceConfig : what the page of an instance says about its configuration
+type instanceConfig struct {
+	version    int      // api version
+	expiries   []string // the expire values it accepts
+	formats    []string // the formats it accepts
+	discussion bool     // whether pastes may be opened for discussion
+	burnPrefix bool     // whether links to burn after reading pastes may start the key with "-"; older versions can't decode it
+}
+
+var (
+	// privatebin.js?1.2.1 (or zerobin.js?Alpha0.19) in the instance's page tells the version apart
+	privateBinScript = regexp.MustCompile(`(privatebin|zerobin)\.js\?[^0-9"']*(\d+)\.(\d+)`)
+	// <select id="pasteExpiration" name="pasteExpiration"><option value="5min">...</select>, and the same for pasteFormatter
+	privateBinSelect = regexp.MustCompile(`(?s)<select[^>]*id="(pasteExpiration|pasteFormatter)"[^>]*>(.*?)</select>`)
+	privateBinOption = regexp.MustCompile(`<option[^>]*value="([^"]*)"`)
+)
+
+// instance fetches the page of host once and reads its configuration off it; anything the page doesn't say
+// is assumed to be as on a stock instance
+func (pbinReciever *PrivateBin) instance(ctx context.Context, host string) (instanceConfig, error) {
+	if config, ok := pbinReciever.instances.Load(host); ok {
+		return config.(instanceConfig), nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", host, nil)
+	if err != nil {
+		return instanceConfig{}, err
+	}
+	resp, err := pbinReciever.HTTPClient.Do(req)
+	if err != nil {
+		return instanceConfig{}, fmt.Errorf("reading the instance's configuration: %s", err)
+	}
+	defer resp.Body.Close()
+	page, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return instanceConfig{}, fmt.Errorf("reading the instance's configuration: %s", err)
+	}
+
+	config := instanceConfig{version: 2, expiries: pasteExpiries, formats: pasteFormats, discussion: true} // anything we can't recognize is assumed recent
+	if match := privateBinScript.FindSubmatch(page); match != nil {
+		major, _ := strconv.Atoi(string(match[2]))
+		minor, _ := strconv.Atoi(string(match[3]))
+		if string(match[1]) == "zerobin" || major < 1 || (major == 1 && minor < 3) {
+			config.version = 1
+		}
+		config.burnPrefix = string(match[1]) == "privatebin" && (major > 1 || (major == 1 && minor >= 6))
+		// the discussion checkbox is left out of the page when discussions are disabled
+		config.discussion = strings.Contains(string(page), `id="opendiscussion"`)
+	}
+	for _, match := range privateBinSelect.FindAllSubmatch(page, -1) {
+		var values []string
+		for _, option := range privateBinOption.FindAllSubmatch(match[2], -1) {
+			values = append(values, string(option[1]))
+		}
+		if len(values) == 0 {
+			continue
+		}
+		if string(match[1]) == "pasteExpiration" {
+			config.expiries = values
+		} else {
+			config.formats = values
+		}
+	}
+	pbinReciever.instances.Store(host, config)
+	return config, nil
+}
+
+// apiVersion returns the api version to talk to host with: the --api-version option, or what the instance's page says
+func (pbinReciever *PrivateBin) apiVersion(ctx context.Context, host string) (int, error) {
+	switch pbinReciever.Options.APIVersion {
+	case "1":
+		return 1, nil
+	case "2":
+		return 2, nil
+	case "", "auto":
+	default:
+		return 0, fmt.Errorf("unknown api version %s; values: [auto, 1, 2]", pbinReciever.Options.APIVersion)
+	}
+	config, err := pbinReciever.instance(ctx, host)
+	return config.version, err
+}
+
+// checkOptions makes sure the instance at host takes the options, before anything gets encrypted
+func (pbinReciever *PrivateBin) checkOptions(ctx context.Context, host string) error {
+	options := pbinReciever.Options
+	if options.Compression != "zlib" && options.Compression != "none" {
+		return fmt.Errorf("unknown compression %s; values: [zlib, none]", options.Compression)
+	}
+	if options.Language != "" && options.Format != "syntaxhighlighting" {
+		return fmt.Errorf("a language hint needs --format syntaxhighlighting")
+	}
+	config, err := pbinReciever.instance(ctx, host)
+	if err != nil {
+		return err
+	}
+	if contains(config.expiries, options.Expire) == false {
+		return fmt.Errorf("%s does not take --days %s; values: [%s]", host, options.Expire, strings.Join(config.expiries, ", "))
+	}
+	if contains(config.formats, options.Format) == false {
+		return fmt.Errorf("%s does not take --format %s; values: [%s]", host, options.Format, strings.Join(config.formats, ", "))
+	}
+	if options.OpenDiscussion != 0 && config.discussion == false {
+		return fmt.Errorf("%s has discussions disabled", host)
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
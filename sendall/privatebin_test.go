@@ -0,0 +1,530 @@
+package sendall
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/btcsuite/btcutil/base58"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// newTestPrivateBin serves handler until the test ends, and returns a client of it with the default options
+func newTestPrivateBin(t *testing.T, handler http.Handler) *PrivateBin {
+	testServer := httptest.NewServer(handler)
+	t.Cleanup(testServer.Close)
+	options := DefaultPrivateBinOptions()
+	options.Host = testServer.URL
+	return NewPrivateBin(options)
+}
+
+// mockPrivateBin keeps posted pastes in memory and hands them back the way the php backend does
+type mockPrivateBin struct {
+	sync.Mutex
+	pastes   map[string][]byte                   // paste id -> posted json
+	comments map[string][]map[string]interface{} // paste id -> posted comments
+	page     string                              // the instance's html page; none if empty
+}
+
+func (mock *mockPrivateBin) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	mock.Lock()
+	defer mock.Unlock()
+	if req.Method == "GET" && req.URL.RawQuery == "" && mock.page != "" {
+		fmt.Fprint(w, mock.page)
+		return
+	}
+	if req.Method == "POST" {
+		body, _ := ioutil.ReadAll(req.Body)
+		var comment map[string]interface{}
+		json.Unmarshal(body, &comment)
+		if token, ok := comment["deletetoken"]; ok {
+			if _, ok = mock.pastes[comment["pasteid"].(string)]; ok == false {
+				fmt.Fprint(w, `{"status":1,"message":"Paste does not exist, has expired or has been deleted."}`)
+			} else if token != "deadbeef" {
+				fmt.Fprint(w, `{"status":1,"message":"Wrong deletion token. Paste was not deleted."}`)
+			} else {
+				delete(mock.pastes, comment["pasteid"].(string))
+				fmt.Fprintf(w, `{"status":0,"id":"%s"}`, comment["pasteid"])
+			}
+			return
+		}
+		if comment["pasteid"] != nil {
+			pasteId := comment["pasteid"].(string)
+			comment["id"] = fmt.Sprintf("c%015x", len(mock.comments[pasteId])+1)
+			comment["meta"] = map[string]interface{}{"created": 1600000000 + len(mock.comments[pasteId])}
+			mock.comments[pasteId] = append(mock.comments[pasteId], comment)
+			fmt.Fprintf(w, `{"status":0,"id":"%s","url":"/?%s"}`, comment["id"], pasteId)
+			return
+		}
+		id := fmt.Sprintf("%016x", len(mock.pastes)+1)
+		mock.pastes[id] = body
+		fmt.Fprintf(w, `{"status":0,"id":"%s","url":"/?%s","deletetoken":"deadbeef"}`, id, id)
+		return
+	}
+	posted, ok := mock.pastes[req.URL.Query().Get("pasteid")]
+	if ok == false {
+		fmt.Fprint(w, `{"status":1,"message":"Paste does not exist, has expired or has been deleted."}`)
+		return
+	}
+	var paste map[string]interface{}
+	json.Unmarshal(posted, &paste)
+	paste["status"] = 0
+	paste["id"] = req.URL.Query().Get("pasteid")
+	paste["comments"] = mock.comments[req.URL.Query().Get("pasteid")]
+	answer, _ := json.Marshal(paste)
+	w.Write([]byte(strings.ReplaceAll(string(answer), "/", `\/`))) // php's json_encode escapes slashes
+}
+
+// mockPrivateBinV1 speaks the form based api of privatebin 1.0-1.2
+type mockPrivateBinV1 struct {
+	sync.Mutex
+	pastes   map[string]map[string]interface{}
+	comments map[string][]map[string]interface{}
+}
+
+func (mock *mockPrivateBinV1) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	mock.Lock()
+	defer mock.Unlock()
+	if req.Method == "POST" {
+		if err := req.ParseForm(); err != nil || req.PostForm.Get("data") == "" {
+			fmt.Fprint(w, `{"status":1,"message":"Invalid data."}`)
+			return
+		}
+		form := req.PostForm
+		if pasteId := form.Get("pasteid"); pasteId != "" {
+			id := fmt.Sprintf("c%015x", len(mock.comments[pasteId])+1)
+			mock.comments[pasteId] = append(mock.comments[pasteId], map[string]interface{}{"id": id, "parentid": form.Get("parentid"),
+				"data": form.Get("data"), "meta": map[string]interface{}{"nickname": form.Get("nickname"), "postdate": 1500000000}})
+			fmt.Fprintf(w, `{"status":0,"id":"%s"}`, id)
+			return
+		}
+		id := fmt.Sprintf("%016x", len(mock.pastes)+1)
+		mock.pastes[id] = map[string]interface{}{"id": id, "data": form.Get("data"), "attachment": form.Get("attachment"),
+			"attachmentname": form.Get("attachmentname"), "meta": map[string]interface{}{"formatter": form.Get("formatter"),
+				"opendiscussion": form.Get("opendiscussion") == "1", "burnafterreading": form.Get("burnafterreading") == "1"}}
+		fmt.Fprintf(w, `{"status":0,"id":"%s","url":"/?%s","deletetoken":"deadbeef"}`, id, id)
+		return
+	}
+	if req.URL.RawQuery == "" {
+		fmt.Fprint(w, `<script type="text/javascript" src="js/privatebin.js?1.2.1" integrity="sha512-..."></script>
+			<input type="checkbox" id="opendiscussion" name="opendiscussion" />`)
+		return
+	}
+	paste, ok := mock.pastes[req.URL.Query().Get("pasteid")]
+	if ok == false {
+		fmt.Fprint(w, `{"status":1,"message":"Paste does not exist, has expired or has been deleted."}`)
+		return
+	}
+	paste["status"] = 0
+	paste["comments"] = mock.comments[req.URL.Query().Get("pasteid")]
+	answer, _ := json.Marshal(paste)
+	w.Write(answer)
+}
+
+func TestPrivateBinV1(t *testing.T) {
+	mock := &mockPrivateBinV1{pastes: map[string]map[string]interface{}{}, comments: map[string][]map[string]interface{}{}}
+	pbin := newTestPrivateBin(t, mock) // api version auto; the mock's page says 1.2.1
+	pbin.Options.OpenDiscussion = 1
+	pbin.Options.Password = "hunter2"
+	result, err := pbin.Upload(context.Background(), UploadRequest{Path: "stdin", Body: strings.NewReader("incident #42")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pbin.Comment(context.Background(), result.URL, "alice", "looking into it", ""); err != nil {
+		t.Fatal(err)
+	}
+	paste, err := pbin.Get(context.Background(), result.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paste.Text != "incident #42" || paste.OpenDiscussion != 1 || paste.Format != "plaintext" {
+		t.Errorf("bad paste: %+v", paste)
+	}
+	if len(paste.Comments) != 1 || paste.Comments[0].Nickname != "alice" || paste.Comments[0].Text != "looking into it" {
+		t.Errorf("bad comments: %+v", paste.Comments)
+	}
+
+	options := pbin.Options
+	options.Password = ""
+	if _, err = NewPrivateBin(options).Get(context.Background(), result.URL); err != ErrBadKey {
+		t.Errorf("expected ErrBadKey without the password, got %v", err)
+	}
+}
+
+// the relevant bits of the page of an instance configured with fewer expiry options, and discussions disabled
+const restrictedInstancePage = `<script type="text/javascript" data-cfasync="false" src="js/privatebin.js?1.3.4" integrity="sha512-..." crossorigin="anonymous"></script>
+<select id="pasteExpiration" name="pasteExpiration">
+	<option value="1day">1 day</option>
+	<option value="1week" selected="selected">1 week</option>
+</select>
+<select id="pasteFormatter" name="pasteFormatter">
+	<option value="plaintext" selected="selected">Plain Text</option>
+	<option value="syntaxhighlighting">Source Code</option>
+</select>`
+
+func TestPrivateBinCheckOptions(t *testing.T) {
+	posted := false
+	pbin := newTestPrivateBin(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "POST" {
+			posted = true
+			fmt.Fprint(w, `{"status":1,"message":"Please wait 10 seconds between each post."}`)
+			return
+		}
+		fmt.Fprint(w, restrictedInstancePage)
+	}))
+
+	for _, bad := range []func(*PrivateBinOptions){
+		func(options *PrivateBinOptions) { options.Expire = "1year" },
+		func(options *PrivateBinOptions) { options.Format = "markdown" },
+		func(options *PrivateBinOptions) { options.OpenDiscussion = 1 },
+		func(options *PrivateBinOptions) { options.Language = "go" }, // plaintext
+	} {
+		options := pbin.Options
+		bad(&options)
+		if _, err := NewPrivateBin(options).Upload(context.Background(), UploadRequest{Path: "stdin", Body: strings.NewReader("x")}); err == nil {
+			t.Errorf("uploaded with %+v", options)
+		}
+	}
+	if posted {
+		t.Error("posted a paste with options the instance does not take")
+	}
+
+	pbin.Options.Format = "syntaxhighlighting"
+	pbin.Options.Language = "go"
+	_, err := pbin.Upload(context.Background(), UploadRequest{Path: "stdin", Body: strings.NewReader("package main")})
+	if err == nil || strings.Contains(err.Error(), "Please wait 10 seconds") == false {
+		t.Errorf("expected the server's message, got %v", err)
+	}
+}
+
+// reference vectors of the v1 format, with a fixed iv and salt. they come from a node script written after
+// privatebin 1.0-1.2's sjcl.encrypt() call, not from privatebin itself: they pin our reading of the format down,
+// they don't prove compatibility with real instances
+const (
+	vectorKeyV1      = "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8="
+	vectorDataV1     = `{"iv":"oKGio6SlpqeoqaqrrK2urw==","v":1,"iter":10000,"ks":256,"ts":128,"mode":"gcm","adata":"","cipher":"aes","salt":"c2FsdHNhbHQ=","ct":"B2vuTEuhbFOp/s32svlUaAgX6uEm0opokBEm+ygHYjo="}`
+	vectorDataV1Hunt = `{"iv":"oKGio6SlpqeoqaqrrK2urw==","v":1,"iter":10000,"ks":256,"ts":128,"mode":"gcm","adata":"","cipher":"aes","salt":"c2FsdHNhbHQ=","ct":"VHMZSBghBIHk3Uqr8ummpeWvAoQ/14BEAZqT46/hglw="}` // password hunter2
+)
+
+func TestPrivateBinV1ReferenceVector(t *testing.T) {
+	for password, data := range map[string]string{"": vectorDataV1, "hunter2": vectorDataV1Hunt} {
+		text, err := decryptV1(data, keyMaterialV1(vectorKeyV1, password))
+		if err != nil {
+			t.Fatalf("password %q: %s", password, err)
+		}
+		if text != "line 1\nline 1\nline 1\nline 1\n" {
+			t.Errorf("password %q: got %q", password, text)
+		}
+	}
+	if _, err := decryptV1(vectorDataV1, keyMaterialV1(vectorKeyV1, "hunter2")); err != ErrBadKey {
+		t.Errorf("expected ErrBadKey with a wrong password, got %v", err)
+	}
+}
+
+func TestPrivateBinUploadAndGet(t *testing.T) {
+	mock := &mockPrivateBin{pastes: map[string][]byte{}}
+	pbin := newTestPrivateBin(t, mock)
+	pbin.Options.Format = "markdown"
+
+	result, err := pbin.Upload(context.Background(), UploadRequest{Path: "/etc/passwd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paste, err := pbin.Get(context.Background(), result.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original, _ := ioutil.ReadFile("/etc/passwd")
+	if paste.Text != string(original) {
+		t.Errorf("decrypted paste differs from the original:\n%s", paste.Text)
+	}
+	if paste.Format != "markdown" {
+		t.Errorf("expected format markdown, got %s", paste.Format)
+	}
+
+	// a wrong key must not decrypt
+	if _, err = pbin.Get(context.Background(), result.URL[:strings.Index(result.URL, "#")]+"#"+strings.Repeat("1", 44)); err == nil {
+		t.Error("decrypted a paste with the wrong key")
+	}
+	if _, err = pbin.Get(context.Background(), pbin.Options.Host+"/?0123456789abcdef#"+result.Key); err == nil {
+		t.Error("got a paste that does not exist")
+	}
+}
+
+func TestPrivateBinParallel(t *testing.T) {
+	mock := &mockPrivateBin{pastes: map[string][]byte{}}
+	pbin := newTestPrivateBin(t, mock)
+	uploads := make([]UploadRequest, 12)
+	for i := range uploads {
+		uploads[i] = UploadRequest{Path: fmt.Sprint("paste", i), Body: strings.NewReader(fmt.Sprint("text of paste ", i))}
+	}
+	results, errs := UploadParallel(context.Background(), pbin, uploads, 4)
+	for i, result := range results {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		// every key must open the paste it was handed out with
+		paste, err := pbin.Get(context.Background(), result.URL)
+		if err != nil {
+			t.Fatalf("paste %d: %s", i, err)
+		}
+		if paste.Text != fmt.Sprint("text of paste ", i) {
+			t.Errorf("paste %d reads %q", i, paste.Text)
+		}
+	}
+}
+
+func TestPrivateBinDelete(t *testing.T) {
+	mock := &mockPrivateBin{pastes: map[string][]byte{}}
+	pbin := newTestPrivateBin(t, mock)
+	result, err := pbin.Upload(context.Background(), UploadRequest{Path: "stdin", Body: strings.NewReader("oops")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := NewRecord(result)
+
+	wrongToken := rec
+	wrongToken.DeleteURL = strings.Replace(rec.DeleteURL, "deadbeef", "cafebabe", 1)
+	if err = pbin.Delete(context.Background(), wrongToken); err == nil || err == ErrGone {
+		t.Errorf("expected a failure with the wrong token, got %v", err)
+	}
+	if err = pbin.Delete(context.Background(), rec); err != nil {
+		t.Fatal(err)
+	}
+	if err = pbin.Delete(context.Background(), rec); err != ErrGone {
+		t.Errorf("expected ErrGone deleting twice, got %v", err)
+	}
+}
+
+func TestPrivateBinBurnLink(t *testing.T) {
+	var tests = []struct {
+		version string
+		burn    int
+		prefix  bool
+	}{
+		{"1.6.0", 1, true},
+		{"1.6.0", 0, false},
+		{"1.7.1", 1, true},
+		{"1.5.2", 1, false}, // base58-decodes the whole fragment, "-" included
+		{"", 1, false},      // a page that doesn't tell
+	}
+	for _, test := range tests {
+		mock := &mockPrivateBin{pastes: map[string][]byte{}}
+		if test.version != "" {
+			mock.page = `<html><script src="js/privatebin.js?` + test.version + `" integrity="sha512-..."></script><input id="opendiscussion"></html>`
+		}
+		pbin := newTestPrivateBin(t, mock)
+		pbin.Options.BurnAfterReading = test.burn
+		result, err := pbin.Upload(context.Background(), UploadRequest{Name: "welp", Body: strings.NewReader("welp")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if BurnsAfterReading(result.URL) != test.prefix || strings.HasSuffix(result.URL, "#-"+result.Key) != test.prefix {
+			t.Errorf("%q, burn %d: got link %s", test.version, test.burn, result.URL)
+		}
+		if paste, err := pbin.Get(context.Background(), result.URL); err != nil || paste.Text != "welp" {
+			t.Errorf("%q, burn %d: got %q, %v", test.version, test.burn, paste.Text, err)
+		}
+	}
+}
+
+func TestPrivateBinPassword(t *testing.T) {
+	mock := &mockPrivateBin{pastes: map[string][]byte{}}
+	pbin := newTestPrivateBin(t, mock)
+	pbin.Options.Password = "hunter2"
+	result, err := pbin.Upload(context.Background(), UploadRequest{Path: "stdin", Body: strings.NewReader("secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := pbin.Options
+	options.Password = ""
+	reader := NewPrivateBin(options)
+	if _, err = reader.Get(context.Background(), result.URL); err != ErrBadKey {
+		t.Errorf("expected ErrBadKey without the password, got %v", err)
+	}
+	prompted := false
+	reader.PasswordPrompt = func() (string, error) {
+		prompted = true
+		return "hunter2", nil
+	}
+	paste, err := reader.Get(context.Background(), result.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prompted == false || paste.Text != "secret" {
+		t.Errorf("expected the prompted password to open the paste, got %q", paste.Text)
+	}
+}
+
+// reference vectors of the v2 format, with a fixed key, iv and salt. they come from a node script (webcrypto aes-gcm,
+// pbkdf2-sha256, raw deflate, the adata json as aad) written after CryptTool.cipher of privatebin 1.3, not from
+// privatebin itself: they pin our reading of the format down, they don't prove compatibility with real instances
+const (
+	vectorKey      = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+	vectorAdata    = `[["oKGio6SlpqeoqaqrrK2urw==","c2FsdHNhbHQ=",100000,256,128,"aes","gcm","zlib"],"plaintext",0,0]`
+	vectorPaste    = `{"paste":"line 1\nline 1\nline 1\nline 1\n"}`
+	vectorCt       = "gNzZi0ucr4/m1cCLSIzKyxX0gHHGD4A880jih87xu5XOjamPLjgddw=="
+	vectorCtHunter = "ithn9BcDmZLAgkfSEoNjhbWGI4pH1jGcBUC7MgfDUvplO0qjcKd4Bg==" // same, with the password hunter2
+	// same, with the password hunter2 and compression none in the adata; without deflate the output is deterministic
+	vectorCtNone = "Wqw93UhZteVI8uFysK0WhGUhjKYCZhOtM9834ugkNo7zoaQiMi9QTeMDdE2gxJcATljKViy73rktM7s8"
+)
+
+func TestPrivateBinAttachment(t *testing.T) {
+	mock := &mockPrivateBin{pastes: map[string][]byte{}}
+	pbin := newTestPrivateBin(t, mock)
+	pbin.Options.Attach = true
+	pbin.Options.Text = "the screenshot"
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	result, err := pbin.Upload(context.Background(), UploadRequest{Path: "/tmp/shot.png", Body: bytes.NewReader(png)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paste, err := pbin.Get(context.Background(), result.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paste.Text != "the screenshot" || paste.AttachmentName != "shot.png" || paste.AttachmentType != "image/png" {
+		t.Errorf("bad attachment: %q %q %q", paste.Text, paste.AttachmentName, paste.AttachmentType)
+	}
+	if bytes.Equal(paste.Attachment, png) == false {
+		t.Errorf("attachment differs from the original: %q", paste.Attachment)
+	}
+}
+
+func TestPrivateBinArchive(t *testing.T) {
+	mock := &mockPrivateBin{pastes: map[string][]byte{}}
+	pbin := newTestPrivateBin(t, mock)
+	archive, err := NewArchive([]string{"/etc/passwd", "/etc/hostname"}, ArchiveZip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zipped, err := ioutil.ReadAll(archive.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if utf8.Valid(zipped) {
+		t.Fatal("the archive is valid utf-8; it proves nothing")
+	}
+	result, err := pbin.Upload(context.Background(), UploadRequest{Path: archive.Path, Name: archive.Name, Body: bytes.NewReader(zipped)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paste, err := pbin.Get(context.Background(), result.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(paste.Attachment, zipped) == false || paste.AttachmentName != archive.Name {
+		t.Errorf("got %d bytes as %q back, expected the %d bytes of %s", len(paste.Attachment), paste.AttachmentName, len(zipped), archive.Name)
+	}
+}
+
+func TestPrivateBinComments(t *testing.T) {
+	mock := &mockPrivateBin{pastes: map[string][]byte{}, comments: map[string][]map[string]interface{}{}}
+	pbin := newTestPrivateBin(t, mock)
+	pbin.Options.OpenDiscussion = 1
+	result, err := pbin.Upload(context.Background(), UploadRequest{Path: "stdin", Body: strings.NewReader("incident #42")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := pbin.Comment(context.Background(), result.URL, "alice", "looking into it", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pbin.Comment(context.Background(), result.URL, "", "fixed <3", first); err != nil {
+		t.Fatal(err)
+	}
+
+	paste, err := pbin.Get(context.Background(), result.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paste.Comments) != 2 {
+		t.Fatalf("expected 2 comments, got %d", len(paste.Comments))
+	}
+	if alice := paste.Comments[0]; alice.Nickname != "alice" || alice.Text != "looking into it" || alice.ParentId != paste.Id {
+		t.Errorf("bad first comment: %+v", alice)
+	}
+	if reply := paste.Comments[1]; reply.Nickname != "" || reply.Text != "fixed <3" || reply.ParentId != first || reply.Created.IsZero() {
+		t.Errorf("bad reply: %+v", reply)
+	}
+}
+
+func TestPrivateBinReferenceVector(t *testing.T) {
+	key, _ := hex.DecodeString(vectorKey)
+	decoder := json.NewDecoder(strings.NewReader(vectorAdata))
+	decoder.UseNumber()
+	var adata []interface{}
+	if err := decoder.Decode(&adata); err != nil {
+		t.Fatal(err)
+	}
+	for password, ct := range map[string]string{"": vectorCt, "hunter2": vectorCtHunter} {
+		ciphertext, _ := base64.StdEncoding.DecodeString(ct)
+		plaintext, err := decrypt(ciphertext, adata[0], adata, keyMaterial(key, password))
+		if err != nil {
+			t.Fatalf("password %q: %s", password, err)
+		}
+		if string(plaintext) != vectorPaste {
+			t.Errorf("password %q: expected %s, got %s", password, vectorPaste, plaintext)
+		}
+	}
+
+	// and the other way around: what we seal opens the same way
+	iv, _ := jsonBytes(adata[0].([]interface{})[0])
+	salt, _ := jsonBytes(adata[0].([]interface{})[1])
+	aesKey := pbkdf2.Key(keyMaterial(key, "hunter2"), salt, kdfIterations, aesKeySizeBytes, sha256.New)
+	for _, compression := range []string{"zlib", "none"} {
+		ours := generateAuthenticationData(iv, salt, "plaintext", 0, 0, compression)
+		ciphertext, err := encrypt(PasteData{Paste: "line 1\nline 1\nline 1\nline 1\n"}, aesKey, iv, ours)
+		if err != nil {
+			t.Fatalf("compression %s: %s", compression, err)
+		}
+		// deflate implementations differ, so only the uncompressed ciphertext can be compared byte for byte
+		if compression == "none" && base64.StdEncoding.EncodeToString(ciphertext) != vectorCtNone {
+			t.Errorf("compression none: expected the reference ciphertext %s, got %s", vectorCtNone, base64.StdEncoding.EncodeToString(ciphertext))
+		}
+		roundTrip, _ := json.Marshal(ours)
+		decoder = json.NewDecoder(bytes.NewReader(roundTrip))
+		decoder.UseNumber()
+		decoder.Decode(&ours)
+		plaintext, err := decrypt(ciphertext, ours[0], ours, keyMaterial(key, "hunter2"))
+		if err != nil {
+			t.Fatalf("compression %s: %s", compression, err)
+		}
+		if string(plaintext) != vectorPaste {
+			t.Errorf("compression %s: expected %s, got %s", compression, vectorPaste, plaintext)
+		}
+	}
+}
+
+func TestEncryptBadKey(t *testing.T) {
+	iv := make([]byte, nonceSizeBytes)
+	adata := generateAuthenticationData(iv, make([]byte, kdfSaltSize), "plaintext", 0, 0, "none")
+	if _, err := encrypt(PasteData{Paste: "x"}, []byte("short"), iv, adata); err == nil {
+		t.Error("encrypted with a 5 byte key")
+	}
+}
+
+func TestParsePasteUrl(t *testing.T) {
+	host, id, key, err := parsePasteUrl("https://bin.example.com/sub/?f468483c313401e8#-6Sv6TmLNH8mXTLT2cbc9S7bZEnVnPWwvBmzVJCsVJrzK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host != "https://bin.example.com/sub" || id != "f468483c313401e8" || len(base58.Decode(key)) != 32 {
+		t.Errorf("bad parse: %s %s %s", host, id, key)
+	}
+	if _, _, _, err = parsePasteUrl("https://bin.example.com/?f468483c313401e8"); err == nil {
+		t.Error("parsed a url without a key")
+	}
+}
@@ -0,0 +1,228 @@
+package sendall
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// the v1 format of privatebin 1.0-1.2 (and zerobin): every field is encrypted on its own with sjcl
+// and posted as a form (https://github.com/PrivateBin/PrivateBin/wiki/API, as of 1.2)
+const kdfIterationsV1 = 10000 // sjcl's default
+
+// sjclJson : a message encrypted with sjcl.encrypt(); byte fields are base64
+type sjclJson struct {
+	Iv     []byte `json:"iv"`
+	V      int    `json:"v"`
+	Iter   int    `json:"iter"`
+	Ks     int    `json:"ks"` // key size in bits
+	Ts     int    `json:"ts"` // tag size in bits
+	Mode   string `json:"mode"`
+	Adata  string `json:"adata"`
+	Cipher string `json:"cipher"`
+	Salt   []byte `json:"salt"`
+	Ct     []byte `json:"ct"` // tag included
+}
+
+// PasteGetResponseV1 : the server's answer to a JSON request of a v1 paste; data fields hold sjcl json
+type PasteGetResponseV1 struct {
+	Id             string `json:"id"`
+	Data           string `json:"data"`
+	Attachment     string `json:"attachment"`
+	AttachmentName string `json:"attachmentname"`
+	Meta           struct {
+		Formatter        string `json:"formatter"`
+		OpenDiscussion   bool   `json:"opendiscussion"`
+		BurnAfterReading bool   `json:"burnafterreading"`
+	} `json:"meta"`
+	Comments []struct {
+		Id       string `json:"id"`
+		ParentId string `json:"parentid"`
+		Data     string `json:"data"`
+		Meta     struct {
+			Nickname string `json:"nickname"` // sjcl json as well, empty when anonymous
+			PostDate int64  `json:"postdate"`
+		} `json:"meta"`
+	} `json:"comments"`
+}
+
+// keyMaterialV1 is the sjcl password: the base64 key, followed by the hex sha256 of the password if there is one
+func keyMaterialV1(key, password string) []byte {
+	if strings.TrimSpace(password) == "" {
+		return []byte(key)
+	}
+	hash := sha256.Sum256([]byte(password))
+	return []byte(key + hex.EncodeToString(hash[:]))
+}
+
+// encryptV1 deflates message and encrypts it the way privatebin 1.x calls sjcl.encrypt()
+func encryptV1(message string, keyMaterial []byte) (string, error) {
+	compressed, err := compress([]byte(message), "zlib")
+	if err != nil {
+		return "", err
+	}
+	// sjcl encrypts text; privatebin hands it the deflated message in base64
+	plaintext := base64.StdEncoding.EncodeToString(compressed)
+
+	sealed := sjclJson{V: 1, Iter: kdfIterationsV1, Ks: aesKeySizeBytes * 8, Ts: gcmTagSize * 8, Mode: "gcm", Cipher: "aes",
+		Iv: make([]byte, nonceSizeBytes), Salt: make([]byte, kdfSaltSize)}
+	if _, err = io.ReadFull(rand.Reader, sealed.Iv); err != nil {
+		return "", err
+	}
+	if _, err = io.ReadFull(rand.Reader, sealed.Salt); err != nil {
+		return "", err
+	}
+	aesgcm, err := sjclCipher(sealed, keyMaterial)
+	if err != nil {
+		return "", err
+	}
+	sealed.Ct = aesgcm.Seal(nil, sealed.Iv, []byte(plaintext), nil)
+	sealedJson, err := json.Marshal(sealed)
+	return string(sealedJson), err
+}
+
+// decryptV1 reverses encryptV1(); an empty message decrypts to ""
+func decryptV1(message string, keyMaterial []byte) (string, error) {
+	if message == "" {
+		return "", nil
+	}
+	var sealed sjclJson
+	if err := json.Unmarshal([]byte(message), &sealed); err != nil {
+		return "", fmt.Errorf("bad sjcl message: %s", err)
+	}
+	if sealed.Cipher != "aes" || sealed.Mode != "gcm" {
+		return "", fmt.Errorf("unsupported cipher %s-%s (zerobin pastes use ccm, which is not supported)", sealed.Cipher, sealed.Mode)
+	}
+	aesgcm, err := sjclCipher(sealed, keyMaterial)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := aesgcm.Open(nil, sealed.Iv, sealed.Ct, []byte(sealed.Adata))
+	if err != nil {
+		return "", ErrBadKey
+	}
+	compressed, err := base64.StdEncoding.DecodeString(string(plaintext))
+	if err != nil {
+		return "", fmt.Errorf("bad paste: %s", err)
+	}
+	decompressed, err := ioutil.ReadAll(flate.NewReader(bytes.NewReader(compressed)))
+	return string(decompressed), err
+}
+
+func sjclCipher(sealed sjclJson, keyMaterial []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2.Key(keyMaterial, sealed.Salt, sealed.Iter, sealed.Ks/8, sha256.New))
+	if err != nil {
+		return nil, err
+	}
+	if sealed.Ts != gcmTagSize*8 {
+		return nil, fmt.Errorf("unsupported tag size of %d bits", sealed.Ts)
+	}
+	return cipher.NewGCMWithNonceSize(block, len(sealed.Iv))
+}
+
+// pasteFormV1 encrypts pasteData into the form privatebin 1.x expects, and returns it along with the (base64) key
+func (pbinReciever *PrivateBin) pasteFormV1(pasteData PasteData) (url.Values, string, error) {
+	rawKey := make([]byte, aesKeySizeBytes)
+	if _, err := io.ReadFull(rand.Reader, rawKey); err != nil {
+		return nil, "", err
+	}
+	key := base64.StdEncoding.EncodeToString(rawKey)
+	keyMaterial := keyMaterialV1(key, pbinReciever.Options.Password)
+
+	form := url.Values{}
+	form.Set("expire", pbinReciever.Options.Expire)
+	form.Set("formatter", pbinReciever.Options.Format)
+	form.Set("opendiscussion", strconv.Itoa(pbinReciever.Options.OpenDiscussion))
+	form.Set("burnafterreading", strconv.Itoa(pbinReciever.Options.BurnAfterReading))
+	fields := map[string]string{"data": pasteData.Paste}
+	if pasteData.Attachment != "" {
+		fields["attachment"], fields["attachmentname"] = pasteData.Attachment, pasteData.AttachementName
+	}
+	for field, value := range fields {
+		sealed, err := encryptV1(value, keyMaterial)
+		if err != nil {
+			return nil, "", err
+		}
+		form.Set(field, sealed)
+	}
+	return form, key, nil
+}
+
+// commentFormV1 encrypts a comment into the form privatebin 1.x expects
+func (pbinReciever *PrivateBin) commentFormV1(key, pasteId, parentId, nickname, text string) (url.Values, error) {
+	keyMaterial := keyMaterialV1(key, pbinReciever.Options.Password)
+	form := url.Values{"pasteid": {pasteId}, "parentid": {parentId}}
+	data, err := encryptV1(text, keyMaterial)
+	if err != nil {
+		return nil, err
+	}
+	form.Set("data", data)
+	if nickname != "" {
+		if nickname, err = encryptV1(nickname, keyMaterial); err != nil {
+			return nil, err
+		}
+		form.Set("nickname", nickname)
+	}
+	return form, nil
+}
+
+// openPasteV1 decrypts a v1 paste, as fetched by Get()
+func openPasteV1(body []byte, key, password string) (Paste, error) {
+	var (
+		paste    Paste
+		response PasteGetResponseV1
+		err      error
+	)
+	if err = json.Unmarshal(body, &response); err != nil {
+		return paste, fmt.Errorf("json decoding error: %s", err)
+	}
+	keyMaterial := keyMaterialV1(key, password)
+	paste = Paste{Id: response.Id, Format: response.Meta.Formatter}
+	if response.Meta.OpenDiscussion {
+		paste.OpenDiscussion = 1
+	}
+	if response.Meta.BurnAfterReading {
+		paste.BurnAfterReading = 1
+	}
+	if paste.Text, err = decryptV1(response.Data, keyMaterial); err != nil {
+		return paste, err
+	}
+	if response.Attachment != "" {
+		attachment, err := decryptV1(response.Attachment, keyMaterial)
+		if err != nil {
+			return paste, err
+		}
+		if paste.AttachmentType, paste.Attachment, err = parseDataUri(attachment); err != nil {
+			return paste, err
+		}
+		if paste.AttachmentName, err = decryptV1(response.AttachmentName, keyMaterial); err != nil {
+			return paste, err
+		}
+	}
+	for _, response := range response.Comments {
+		comment := Comment{Id: response.Id, ParentId: response.ParentId, Created: time.Unix(response.Meta.PostDate, 0)}
+		if comment.Text, err = decryptV1(response.Data, keyMaterial); err != nil {
+			return paste, fmt.Errorf("comment %s: %s", response.Id, err)
+		}
+		if comment.Nickname, err = decryptV1(response.Meta.Nickname, keyMaterial); err != nil {
+			return paste, fmt.Errorf("comment %s: %s", response.Id, err)
+		}
+		paste.Comments = append(paste.Comments, comment)
+	}
+	return paste, nil
+}
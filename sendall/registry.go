@@ -0,0 +1,109 @@
+package sendall
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Backend : how a service describes itself to the registry; the cli builds its commands out of this
+type Backend struct {
+	Name         string                               `json:"name"`        // command name, e.g. "transfer"
+	StoreName    string                               `json:"store_name"`  // what the service's Name() returns; records are stored under it
+	Description  string                               `json:"description"` // one line shown in help and in "sendall services"
+	Options      []Option                             `json:"options"`     // option schema; becomes the command's flags
+	Capabilities Capabilities                         `json:"capabilities"`
+	New          func(values Values) (Service, error) `json:"-"` // builds the service; values hold every option, defaults included
+}
+
+// Capabilities : what a backend can do besides uploading
+type Capabilities struct {
+	Delete     bool  `json:"delete"`     // files can be removed with the delete url
+	Download   bool  `json:"download"`   // files can be fetched back
+	Encryption bool  `json:"encryption"` // files are encrypted before leaving the machine
+	MaxSize    int64 `json:"max_size"`   // in bytes; 0 if unknown or unlimited
+}
+
+// Option : a single backend option
+type Option struct {
+	Name      string      `json:"name"`
+	Shorthand string      `json:"shorthand"`
+	Default   interface{} `json:"default"` // string, int or bool; decides the option's type
+	Usage     string      `json:"usage"`
+	Secret    bool        `json:"secret"` // e.g. a password; "-" asks for it on the terminal instead of taking it from the command line
+}
+
+// Values : option values keyed by option name
+type Values map[string]interface{}
+
+func (values Values) String(name string) string {
+	value, _ := values[name].(string)
+	return value
+}
+
+func (values Values) Int(name string) int {
+	value, _ := values[name].(int)
+	return value
+}
+
+func (values Values) Bool(name string) bool {
+	value, _ := values[name].(bool)
+	return value
+}
+
+var (
+	registry     = make(map[string]Backend)
+	registryLock sync.RWMutex
+)
+
+// Register adds a backend to the registry; meant to be called from the backend's init()
+func Register(backend Backend) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if _, exists := registry[backend.Name]; exists {
+		panic(fmt.Sprintf("sendall: backend %s registered twice", backend.Name))
+	}
+	registry[backend.Name] = backend
+}
+
+// Lookup returns the backend registered under name
+func Lookup(name string) (Backend, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	backend, ok := registry[name]
+	return backend, ok
+}
+
+// Backends returns every registered backend, sorted by name
+func Backends() []Backend {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	backends := make([]Backend, 0, len(registry))
+	for _, backend := range registry {
+		backends = append(backends, backend)
+	}
+	sort.Slice(backends, func(i, j int) bool { return backends[i].Name < backends[j].Name })
+	return backends
+}
+
+// LookupStoreName returns the backend whose records are stored under name
+func LookupStoreName(name string) (Backend, bool) {
+	for _, backend := range Backends() {
+		if backend.StoreName == name {
+			return backend, true
+		}
+	}
+	return Backend{}, false
+}
+
+// NewService builds the backend's service; options missing from values take their defaults
+func (backend Backend) NewService(values Values) (Service, error) {
+	merged := make(Values, len(backend.Options))
+	for _, option := range backend.Options {
+		merged[option.Name] = option.Default
+	}
+	for name, value := range values {
+		merged[name] = value
+	}
+	return backend.New(merged)
+}
@@ -0,0 +1,202 @@
+package sendall
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// RetryPolicy : how requests that failed for a transient reason (a network error, 429 or 5xx) are tried again
+type RetryPolicy struct {
+	Retries    int           // tries after the first one; 0 never retries
+	Backoff    time.Duration // wait before the first retry, doubled on every retry after it
+	MaxBackoff time.Duration // the wait never grows past this, unless the server asks for longer with Retry-After
+	Jitter     float64       // up to this fraction of the wait is added at random, so parallel uploads don't retry in lockstep
+	Timeout    time.Duration // of each try, response body included; 0 for none
+	// the longest Retry-After waited for; a server asking for more is given up on right away. 0 is MaxBackoff
+	MaxRetryAfter time.Duration
+}
+
+// DefaultRetryPolicy returns the policy services use unless told otherwise
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		Retries:    3,
+		Backoff:    time.Second,
+		MaxBackoff: 30 * time.Second,
+		Jitter:     0.5,
+
+		MaxRetryAfter: 2 * time.Minute,
+	}
+}
+
+// Retrier : implemented by services that can retry their requests
+type Retrier interface {
+	SetRetryPolicy(policy RetryPolicy)
+}
+
+// retryTransport retries the requests going through it by its policy. requests with a body are only
+// retried if they have GetBody to start the body over (http.NewRequest sets it for in-memory bodies).
+// POSTs create something on every try (a paste, a comment), so they are only retried when the server
+// turned them away with 429 or 503, or when they never made it to the server
+type retryTransport struct {
+	base   http.RoundTripper
+	policy RetryPolicy
+}
+
+// retryingClient returns a copy of client whose requests are retried by policy
+func retryingClient(client *http.Client, policy RetryPolicy) *http.Client {
+	retrying := *client
+	base := client.Transport
+	if transport, ok := base.(*retryTransport); ok { // don't retry retries
+		base = transport.base
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	retrying.Transport = &retryTransport{base: base, policy: policy}
+	return &retrying
+}
+
+func (transport *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	for attempt := 0; ; attempt++ {
+		resp, sent, err := transport.try(req)
+		wait, retry, waitErr := transport.policy.retryWait(attempt, req, resp, err)
+		if idempotent(req) == false && resendable(resp, sent, err) == false {
+			retry = false
+		}
+		if retry == false || attempt >= transport.policy.Retries || replayable == false || req.Context().Err() != nil {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4<<10)) // lets the connection be reused
+			resp.Body.Close()
+		}
+		if waitErr != nil {
+			return nil, waitErr
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// try sends req once, within the policy's timeout if there's one. sent tells whether the server
+// could have seen any of the request
+func (transport *retryTransport) try(req *http.Request) (resp *http.Response, sent bool, err error) {
+	var wrote int32 // set from the transport's own goroutine
+	ctx := httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		WroteHeaders: func() { atomic.StoreInt32(&wrote, 1) },
+	})
+	cancel := context.CancelFunc(func() {})
+	if transport.policy.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, transport.policy.Timeout)
+	}
+	resp, err = transport.base.RoundTrip(req.WithContext(ctx))
+	sent = atomic.LoadInt32(&wrote) == 1
+	if err != nil {
+		cancel()
+		return nil, sent, err
+	}
+	resp.Body = &cancelingBody{ReadCloser: resp.Body, cancel: cancel} // the timeout covers reading the body too
+	return resp, sent, nil
+}
+
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case "GET", "HEAD", "PUT", "DELETE", "OPTIONS", "TRACE":
+		return true
+	}
+	return false
+}
+
+// resendable tells whether a request that isn't idempotent can be tried again without the risk of a
+// duplicate: the server refused it before doing anything, or never got it
+func resendable(resp *http.Response, sent bool, err error) bool {
+	if err != nil {
+		return sent == false // e.g. the connection was refused
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+}
+
+type cancelingBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (body *cancelingBody) Close() error {
+	err := body.ReadCloser.Close()
+	body.cancel()
+	return err
+}
+
+// retryWait tells whether the outcome of a try is worth another one, and how long to wait before it.
+// waitErr is set when the server asks to wait longer than the policy allows, which ends the retries
+func (policy RetryPolicy) retryWait(attempt int, req *http.Request, resp *http.Response, err error) (time.Duration, bool, error) {
+	switch {
+	case err != nil: // the network, or our own timeout
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+		if wait, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			limit := policy.MaxRetryAfter
+			if limit <= 0 {
+				limit = policy.MaxBackoff
+			}
+			if wait > limit {
+				return 0, true, fmt.Errorf("server replied with %s and asks to wait %s before trying again, longer than the %s sendall waits", resp.Status, wait, limit)
+			}
+			return wait, true, nil
+		}
+	case resp.StatusCode == http.StatusInternalServerError && req.Header.Get("X-Decrypt-Password") != "":
+		// transfer.sh answers a wrong decrypt password with 500; no retry fixes that, it only keeps the user waiting
+		return 0, false, nil
+	case resp.StatusCode >= 500:
+	default:
+		return 0, false, nil
+	}
+
+	wait := policy.Backoff << uint(attempt)
+	if wait > policy.MaxBackoff || wait <= 0 { // <= 0 once shifted too far
+		wait = policy.MaxBackoff
+	}
+	if policy.Jitter > 0 {
+		wait += time.Duration(rand.Float64() * policy.Jitter * float64(wait))
+	}
+	return wait, true, nil
+}
+
+// retryAfter reads a Retry-After header, either in seconds or an http date
+func retryAfter(header string, now time.Time) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,292 @@
+package sendall
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var fastRetries = RetryPolicy{Retries: 3, Backoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond, Jitter: 0.5}
+
+// the ways a flaky server fails a request
+var (
+	failUnavailable = func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "try again later", http.StatusServiceUnavailable)
+	}
+	failTooManyRequests = func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Retry-After", "0")
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	}
+	failDropConnection = func(w http.ResponseWriter, req *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}
+	failSlowly = func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(200 * time.Millisecond) // past the policy's timeout
+	}
+)
+
+// flakyServer fails the first failures requests it gets with fail, and hands the ones after to handler
+func flakyServer(failures int32, fail, handler http.HandlerFunc) (*httptest.Server, *int32) {
+	var requests int32
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= failures {
+			ioutil.ReadAll(req.Body) // fail after the whole file went through, the worst case for the client
+			fail(w, req)
+			return
+		}
+		handler(w, req)
+	})), &requests
+}
+
+func TestRetryTransferUpload(t *testing.T) {
+	file, err := ioutil.TempFile("", "sendall")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	content := strings.Repeat("worth retrying for\n", 20000)
+	file.WriteString(content)
+	file.Close()
+	contentSum := sha256.Sum256([]byte(content))
+
+	var tests = []struct {
+		name    string
+		fail    http.HandlerFunc
+		timeout time.Duration
+	}{
+		{"503", failUnavailable, 0},
+		{"429 with Retry-After", failTooManyRequests, 0},
+		{"dropped connection", failDropConnection, 0},
+		{"timeout", failSlowly, 50 * time.Millisecond},
+	}
+	for _, test := range tests {
+		var received []byte
+		server, requests := flakyServer(2, test.fail, func(w http.ResponseWriter, req *http.Request) {
+			received, _ = ioutil.ReadAll(req.Body)
+			io.WriteString(w, "http://"+req.Host+"/abcde"+req.URL.Path)
+		})
+		options := DefaultTransferShOptions()
+		options.Host = server.URL
+		transfer := NewTransferSh(options)
+		policy := fastRetries
+		policy.Timeout = test.timeout
+		transfer.SetRetryPolicy(policy)
+
+		result, err := transfer.Upload(context.Background(), UploadRequest{Path: file.Name()})
+		server.Close()
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if *requests != 3 {
+			t.Errorf("%s: expected 3 requests, got %d", test.name, *requests)
+		}
+		if string(received) != content {
+			t.Errorf("%s: the server got %d bytes of %d", test.name, len(received), len(content))
+		}
+		if result.Size != int64(len(content)) || result.SHA256 != hex.EncodeToString(contentSum[:]) {
+			t.Errorf("%s: size %d and sha256 %s are not the file's", test.name, result.Size, result.SHA256)
+		}
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	var tests = []struct {
+		name         string
+		status       int
+		wantRequests int32
+	}{
+		{"always 500", http.StatusInternalServerError, int32(fastRetries.Retries) + 1},
+		{"always 502", http.StatusBadGateway, int32(fastRetries.Retries) + 1},
+		{"404 is final", http.StatusNotFound, 1},
+	}
+	for _, test := range tests {
+		status := test.status
+		server, requests := flakyServer(0, nil, func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(status)
+		})
+		options := DefaultTransferShOptions()
+		options.Host = server.URL
+		transfer := NewTransferSh(options)
+		transfer.SetRetryPolicy(fastRetries)
+		_, err := transfer.Upload(context.Background(), UploadRequest{Name: "welp", Body: strings.NewReader("welp")})
+		server.Close()
+		if err == nil {
+			t.Errorf("%s: upload did not fail", test.name)
+		}
+		if *requests != test.wantRequests {
+			t.Errorf("%s: expected %d requests, got %d", test.name, test.wantRequests, *requests)
+		}
+	}
+}
+
+func TestRetryNeedsReplayableBody(t *testing.T) {
+	var tests = []struct {
+		name string
+		body io.Reader
+		ok   bool
+	}{
+		{"seekable", strings.NewReader("welp"), true},
+		{"stream", struct{ io.Reader }{strings.NewReader("welp")}, false}, // e.g. a pipe: the failed try ate it
+	}
+	for _, test := range tests {
+		server, requests := flakyServer(1, failUnavailable, func(w http.ResponseWriter, req *http.Request) {
+			if body, _ := ioutil.ReadAll(req.Body); string(body) != "welp" {
+				t.Errorf("%s: the server got %q", test.name, body)
+			}
+			io.WriteString(w, "http://"+req.Host+"/abcde"+req.URL.Path)
+		})
+		options := DefaultTransferShOptions()
+		options.Host = server.URL
+		transfer := NewTransferSh(options)
+		transfer.SetRetryPolicy(fastRetries)
+		_, err := transfer.Upload(context.Background(), UploadRequest{Name: "welp", Body: test.body})
+		server.Close()
+		if (err == nil) != test.ok {
+			t.Errorf("%s: expected success %v, got %v", test.name, test.ok, err)
+		}
+		if wantRequests := map[bool]int32{true: 2, false: 1}[test.ok]; *requests != wantRequests {
+			t.Errorf("%s: expected %d requests, got %d", test.name, wantRequests, *requests)
+		}
+	}
+}
+
+func TestRetryPrivateBin(t *testing.T) {
+	var tests = []struct {
+		name      string
+		fail      http.HandlerFunc
+		wantPosts int32
+		ok        bool
+	}{
+		{"503", failUnavailable, 3, true},
+		{"429 with Retry-After", failTooManyRequests, 3, true},
+		// the paste may have been created already; posting it again would make a second one
+		{"dropped connection", failDropConnection, 1, false},
+		{"502", func(w http.ResponseWriter, req *http.Request) { w.WriteHeader(http.StatusBadGateway) }, 1, false},
+	}
+	for _, test := range tests {
+		mock := &mockPrivateBin{pastes: map[string][]byte{}}
+		var posts int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Method == "POST" && atomic.AddInt32(&posts, 1) <= 2 {
+				ioutil.ReadAll(req.Body)
+				test.fail(w, req)
+				return
+			}
+			mock.ServeHTTP(w, req)
+		}))
+		options := DefaultPrivateBinOptions()
+		options.Host = server.URL
+		pbin := NewPrivateBin(options)
+		pbin.SetRetryPolicy(fastRetries)
+
+		result, err := pbin.Upload(context.Background(), UploadRequest{Name: "welp", Body: strings.NewReader("welp")})
+		if posts := atomic.LoadInt32(&posts); (err == nil) != test.ok || posts != test.wantPosts {
+			t.Errorf("%s: expected success %v after %d posts, got %v after %d", test.name, test.ok, test.wantPosts, err, posts)
+		}
+		if test.ok {
+			if paste, err := pbin.Get(context.Background(), result.URL); err != nil || paste.Text != "welp" {
+				t.Errorf("%s: got %q, %v", test.name, paste.Text, err)
+			}
+		}
+		server.Close()
+	}
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (fn roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return fn(req) }
+
+func TestRetryPostNotSent(t *testing.T) {
+	var tries int
+	client := retryingClient(&http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if tries++; tries <= 2 {
+			return nil, errors.New("connection refused") // before a byte of the request was written
+		}
+		body, _ := ioutil.ReadAll(req.Body)
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(body))}, nil
+	})}, fastRetries)
+
+	resp, err := client.Post("http://example.com/", "application/json", strings.NewReader(`{"welp":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if body, _ := ioutil.ReadAll(resp.Body); string(body) != `{"welp":1}` || tries != 3 {
+		t.Errorf("got %q after %d tries", body, tries)
+	}
+}
+
+func TestRetryCancelled(t *testing.T) {
+	server, requests := flakyServer(100, failUnavailable, nil)
+	defer server.Close()
+	options := DefaultTransferShOptions()
+	options.Host = server.URL
+	transfer := NewTransferSh(options)
+	transfer.SetRetryPolicy(RetryPolicy{Retries: 100, Backoff: time.Hour, MaxBackoff: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := transfer.Upload(ctx, UploadRequest{Name: "welp", Body: bytes.NewReader([]byte("welp"))}); err == nil {
+		t.Error("upload did not fail")
+	}
+	if time.Since(start) > time.Second || *requests != 1 {
+		t.Errorf("waited %s for the next retry, after %d requests", time.Since(start), *requests)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	var tests = []struct {
+		header string
+		wait   time.Duration
+		ok     bool
+	}{
+		{"", 0, false},
+		{"120", 2 * time.Minute, true},
+		{"Mon, 01 Jun 2020 12:00:30 GMT", 30 * time.Second, true},
+		{"Mon, 01 Jun 2020 11:00:00 GMT", 0, true}, // already past
+		{"soon", 0, false},
+	}
+	for _, test := range tests {
+		if wait, ok := retryAfter(test.header, now); wait != test.wait || ok != test.ok {
+			t.Errorf("%q: got %s %v, expected %s %v", test.header, wait, ok, test.wait, test.ok)
+		}
+	}
+}
+
+func TestRetryAfterTooLong(t *testing.T) {
+	server, requests := flakyServer(1, func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Retry-After", "86400")
+		http.Error(w, "come back tomorrow", http.StatusTooManyRequests)
+	}, nil)
+	defer server.Close()
+	options := DefaultTransferShOptions()
+	options.Host = server.URL
+	transfer := NewTransferSh(options)
+	transfer.SetRetryPolicy(DefaultRetryPolicy())
+
+	start := time.Now()
+	_, err := transfer.Upload(context.Background(), UploadRequest{Name: "welp", Body: strings.NewReader("welp")})
+	if err == nil || strings.Contains(err.Error(), "24h0m0s") == false {
+		t.Errorf("expected to be told of the wait, got %v", err)
+	}
+	if time.Since(start) > time.Second || *requests != 1 {
+		t.Errorf("waited %s, after %d requests", time.Since(start), *requests)
+	}
+}
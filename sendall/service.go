@@ -0,0 +1,179 @@
+// Package sendall wraps several file sharing backends behind one interface.
+// The sendall command is a thin layer on top of it.
+package sendall
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Service : all services should implement this
+type Service interface {
+	Name() string                                                        // name of the service; doubles as the bucket name in the history store
+	Upload(ctx context.Context, req UploadRequest) (UploadResult, error) // uploads a single file and returns where it ended up
+	Delete(ctx context.Context, rec Record) error                        // asks the service to remove a file uploaded before
+}
+
+// ErrGone : returned by Delete when the file was already deleted, or has expired
+var ErrGone = errors.New("already deleted or expired")
+
+// Prober : implemented by services that can tell whether an upload is still there
+type Prober interface {
+	Alive(ctx context.Context, rec Record) (bool, error) // false once the file is gone (expired, burned by its download limit, deleted)
+}
+
+// Bundler : implemented by services that take several files in one request and serve them back as one archive
+type Bundler interface {
+	UploadBundle(ctx context.Context, uploads []UploadRequest, format string) (UploadResult, error) // format is one of ArchiveZip, ArchiveTarGz
+}
+
+// UploadRequest : a single file to be uploaded
+type UploadRequest struct {
+	Path     string       // local path of the file; opened by the service if Body is nil
+	Name     string       // remote file name; defaults to the base name of Path
+	Body     io.Reader    // optional; read instead of Path (e.g. stdin); closed once uploaded if it's an io.Closer
+	Progress ProgressFunc // optional; told how much of the file was read so far
+}
+
+// ProgressFunc : called by the goroutine uploading a file as it starts and as it reads the file, with the bytes read
+// so far and the size of the file (-1 if unknown, e.g. for stdin); once the file is read through, sent == total.
+// a retry reads the file again, starting over from 0
+type ProgressFunc func(sent, total int64)
+
+// UploadResult : what a service hands back for one uploaded file
+type UploadResult struct {
+	Service      string
+	File         string    // the local file this result belongs to
+	Size         int64     // bytes read from the file
+	URL          string    // share url
+	DeleteURL    string    // url used to remove the file from the service
+	Key          string    // encryption key, if any (already part of URL)
+	ExpiresAt    time.Time // zero if unknown or never
+	MaxDownloads int       // 0 if unlimited
+	SHA256       string    // hex sha256 of what was uploaded, if the service computed it
+}
+
+// Record : an upload as remembered in the history store; it's all Delete() needs
+type Record struct {
+	Service      string    `json:"service"`
+	File         string    `json:"file"` // original file
+	Size         int64     `json:"size"`
+	URL          string    `json:"url"`
+	DeleteURL    string    `json:"delete_url"`
+	UploadedAt   time.Time `json:"uploaded_at"`
+	ExpiresAt    time.Time `json:"expires_at"`    // zero if unknown or never
+	MaxDownloads int       `json:"max_downloads"` // 0 if unlimited
+	GoneAt       time.Time `json:"gone_at"`       // when the file was found gone from the server (e.g. burned after reading); zero until then
+	SHA256       string    `json:"sha256"`        // hex sha256 of what was uploaded; empty if unknown
+}
+
+// Expired tells whether the record's expiry date has passed by now
+func (rec Record) Expired(now time.Time) bool {
+	return rec.ExpiresAt.IsZero() == false && rec.ExpiresAt.Before(now)
+}
+
+// UploadFiles uploads every file concurrently; results and errors are in the same order as files
+func UploadFiles(ctx context.Context, svc Service, files []string) ([]UploadResult, []error) {
+	uploads := make([]UploadRequest, len(files))
+	for i, file := range files {
+		uploads[i] = UploadRequest{Path: file}
+	}
+	return UploadAll(ctx, svc, uploads)
+}
+
+// DefaultParallel : how many uploads UploadAll runs at once
+const DefaultParallel = 4
+
+// UploadAll runs the uploads, DefaultParallel at a time; results and errors are in the same order as uploads
+func UploadAll(ctx context.Context, svc Service, uploads []UploadRequest) ([]UploadResult, []error) {
+	return UploadParallel(ctx, svc, uploads, DefaultParallel)
+}
+
+// UploadParallel runs the uploads with a pool of parallel workers (DefaultParallel if parallel < 1);
+// results and errors are in the same order as uploads. uploads not started by the time ctx is done fail with ctx.Err()
+func UploadParallel(ctx context.Context, svc Service, uploads []UploadRequest, parallel int) ([]UploadResult, []error) {
+	var holup sync.WaitGroup
+
+	if parallel < 1 {
+		parallel = DefaultParallel
+	}
+	results := make([]UploadResult, len(uploads))
+	errs := make([]error, len(uploads))
+	queue := make(chan int)
+	for worker := 0; worker < parallel && worker < len(uploads); worker++ {
+		holup.Add(1)
+		go func() {
+			defer holup.Done()
+			// each upload is handled by one worker only, which alone writes its result
+			for i := range queue {
+				if errs[i] = ctx.Err(); errs[i] == nil {
+					results[i], errs[i] = svc.Upload(ctx, uploads[i])
+				}
+				if closer, ok := uploads[i].Body.(io.Closer); ok {
+					closer.Close() // e.g. lets an archive's writer know nobody is reading anymore
+				}
+			}
+		}()
+	}
+	for i := range uploads {
+		queue <- i
+	}
+	close(queue)
+	holup.Wait()
+	return results, errs
+}
+
+// countingReader counts the bytes read through it, telling progress about it if set
+type countingReader struct {
+	reader   io.Reader
+	count    int64
+	total    int64 // -1 if unknown
+	progress ProgressFunc
+	eof      bool // readers may be read again past their end; progress hears of it once
+}
+
+// uploadCounter counts the bytes read of body, the contents of upload; every backend reads uploads through one
+func uploadCounter(body io.Reader, upload UploadRequest) *countingReader {
+	counter := &countingReader{reader: body, total: -1, progress: upload.Progress}
+	var (
+		info os.FileInfo
+		err  error
+	)
+	if file, ok := upload.Body.(*os.File); ok { // e.g. stdin redirected from a file
+		info, err = file.Stat()
+	} else if upload.Body == nil {
+		info, err = os.Stat(upload.Path)
+	}
+	if err == nil && info != nil && info.Mode().IsRegular() {
+		counter.total = info.Size()
+	}
+	if counter.progress != nil {
+		counter.progress(0, counter.total) // the upload starts now
+	}
+	return counter
+}
+
+func (counter *countingReader) Read(p []byte) (int, error) {
+	n, err := counter.reader.Read(p)
+	counter.count += int64(n)
+	if counter.progress != nil {
+		if err == io.EOF && counter.eof == false {
+			counter.eof = true
+			counter.total = counter.count // the file may have grown or shrunk since it was stat'ed
+			counter.progress(counter.count, counter.total)
+		} else if n > 0 {
+			counter.progress(counter.count, counter.total)
+		}
+	}
+	return n, err
+}
+
+func sanitize(fileName string) string {
+	// didn't know about path.Clean()! credit goes to DutchCoders
+	return filepath.Clean(filepath.Base(fileName))
+}
@@ -0,0 +1,114 @@
+package sendall
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// these tests stay clear of the bolt store, so they can run with -race:
+//
+//	go test -race -run Parallel ./sendall
+
+// slowService takes a while for every upload, and keeps track of how many run at once
+type slowService struct {
+	inFlight, maxInFlight int32
+}
+
+func (svc *slowService) Name() string { return "slow" }
+
+func (svc *slowService) Upload(ctx context.Context, upload UploadRequest) (UploadResult, error) {
+	now := atomic.AddInt32(&svc.inFlight, 1)
+	defer atomic.AddInt32(&svc.inFlight, -1)
+	for {
+		max := atomic.LoadInt32(&svc.maxInFlight)
+		if now <= max || atomic.CompareAndSwapInt32(&svc.maxInFlight, max, now) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	return UploadResult{Service: svc.Name(), File: upload.Path, URL: "https://slow.example.com/" + upload.Path, Key: upload.Path}, nil
+}
+
+func (svc *slowService) Delete(ctx context.Context, rec Record) error { return nil }
+
+func TestUploadParallelBoundsWorkers(t *testing.T) {
+	svc := &slowService{}
+	uploads := make([]UploadRequest, 20)
+	for i := range uploads {
+		uploads[i] = UploadRequest{Path: string(rune('a' + i))}
+	}
+	results, errs := UploadParallel(context.Background(), svc, uploads, 3)
+	for i, result := range results {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if result.File != uploads[i].Path || result.Key != uploads[i].Path {
+			t.Errorf("result %d belongs to %s, not %s", i, result.File, uploads[i].Path)
+		}
+	}
+	if svc.maxInFlight > 3 {
+		t.Errorf("expected at most 3 uploads at once, got %d", svc.maxInFlight)
+	}
+}
+
+func TestUploadParallelCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, errs := UploadParallel(ctx, &slowService{}, []UploadRequest{{Path: "a"}, {Path: "b"}}, 0)
+	for i, err := range errs {
+		if err != context.Canceled {
+			t.Errorf("upload %d: expected context.Canceled, got %v", i, err)
+		}
+	}
+}
+
+func TestUploadCounterProgress(t *testing.T) {
+	file, err := ioutil.TempFile("", "sendall")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	content := strings.Repeat("welp", 50000)
+	file.WriteString(content)
+	file.Close()
+
+	for _, upload := range []UploadRequest{{Path: file.Name()}, {Body: strings.NewReader(content)}} {
+		var sent, total []int64
+		upload.Progress = func(s, t int64) {
+			sent, total = append(sent, s), append(total, t)
+		}
+		body := upload.Body
+		if body == nil {
+			if body, err = os.Open(upload.Path); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err = io.Copy(ioutil.Discard, uploadCounter(body, upload)); err != nil {
+			t.Fatal(err)
+		}
+		if len(sent) < 2 {
+			t.Fatalf("%+v: progress was told %d times", upload, len(sent))
+		}
+		for i := 1; i < len(sent); i++ {
+			if sent[i] < sent[i-1] {
+				t.Errorf("%+v: progress went back from %d to %d", upload, sent[i-1], sent[i])
+			}
+		}
+		wantTotal := int64(-1) // a reader has no size to stat
+		if upload.Body == nil {
+			wantTotal = int64(len(content))
+		}
+		if total[0] != wantTotal {
+			t.Errorf("%+v: expected a size of %d up front, got %d", upload, wantTotal, total[0])
+		}
+		if last := len(sent) - 1; sent[last] != int64(len(content)) || total[last] != sent[last] {
+			t.Errorf("%+v: ended at %d of %d", upload, sent[last], total[last])
+		}
+	}
+}
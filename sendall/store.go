@@ -0,0 +1,331 @@
+package sendall
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	metaBucketName = "sendall.meta" // bookkeeping of the store itself; never holds records
+	schemaVersion  = 1              // 0: bare delete urls as values, 1: json records
+)
+
+// Store : the history store; a bolt db where each service gets its own bucket, mapping posted urls -> records
+type Store struct {
+	Path string // bolt db file
+}
+
+// NewStore returns a store backed by the bolt db at path; the file is created on first use
+func NewStore(path string) *Store {
+	return &Store{Path: path}
+}
+
+// DefaultStorePath returns $XDG_DATA_HOME/sendall/sendall.db, falling back to ~/.local/share when XDG_DATA_HOME is unset
+func DefaultStorePath() string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "sendall.db" // nowhere better to go
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dataHome, "sendall", "sendall.db")
+}
+
+// NewRecord builds the record to be remembered for an upload
+func NewRecord(result UploadResult) Record {
+	return Record{
+		Service:      result.Service,
+		File:         result.File,
+		Size:         result.Size,
+		URL:          result.URL,
+		DeleteURL:    result.DeleteURL,
+		UploadedAt:   time.Now(),
+		ExpiresAt:    result.ExpiresAt,
+		SHA256:       result.SHA256,
+		MaxDownloads: result.MaxDownloads,
+	}
+}
+
+// open opens the db and brings old databases up to date
+func (store *Store) open() (*bolt.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(store.Path), 0700); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(store.Path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	if err = db.Update(migrate); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating %s: %s", store.Path, err)
+	}
+	return db, nil
+}
+
+// Save remembers an upload in the service's bucket
+func (store *Store) Save(result UploadResult) error {
+	return store.Put(NewRecord(result))
+}
+
+// Put writes rec into its service's bucket, replacing any record with the same url
+func (store *Store) Put(rec Record) error {
+	value, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	db, err := store.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(rec.Service))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(rec.URL), value)
+	})
+}
+
+// Find fetches the record saved for url by service
+func (store *Store) Find(service, url string) (Record, error) {
+	var (
+		rec   Record
+		found bool
+	)
+	db, err := store.open()
+	if err != nil {
+		return rec, err
+	}
+	defer db.Close()
+	err = db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(service))
+		if bucket == nil {
+			return nil
+		}
+		if value := bucket.Get([]byte(url)); value != nil {
+			found = true
+			return json.Unmarshal(value, &rec)
+		}
+		return nil
+	})
+	if err != nil {
+		return rec, err
+	}
+	if found == false {
+		return rec, fmt.Errorf("link %s does not have an entry in db", url)
+	}
+	return rec, nil
+}
+
+// Get fetches the record saved for url, whichever service it was posted to
+func (store *Store) Get(url string) (Record, error) {
+	records, err := store.List()
+	if err != nil {
+		return Record{}, err
+	}
+	for _, rec := range records {
+		if rec.URL == url {
+			return rec, nil
+		}
+	}
+	return Record{}, fmt.Errorf("link %s does not have an entry in db", url)
+}
+
+// MarkGone remembers that the file at url is no longer on the server; the record stays until gc
+func (store *Store) MarkGone(url string) error {
+	rec, err := store.Get(url)
+	if err != nil {
+		return err
+	}
+	rec.GoneAt = time.Now()
+	return store.Put(rec)
+}
+
+// List returns every record of every service, oldest upload first
+func (store *Store) List() ([]Record, error) {
+	records := []Record{}
+	db, err := store.open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	err = db.View(func(tx *bolt.Tx) error {
+		return eachRecord(tx, func(bucket *bolt.Bucket, rec Record) error {
+			records = append(records, rec)
+			return nil
+		})
+	})
+	sort.SliceStable(records, func(i, j int) bool { return records[i].UploadedAt.Before(records[j].UploadedAt) })
+	return records, err
+}
+
+// Import copies every record of the db at path into the store; records already in the store are kept as they are
+func (store *Store) Import(path string) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, err
+	}
+	records, err := NewStore(path).List() // brings the other db up to date as well
+	if err != nil {
+		return 0, err
+	}
+	imported := 0
+	for _, rec := range records {
+		if _, err = store.Find(rec.Service, rec.URL); err == nil {
+			continue
+		}
+		if err = store.Put(rec); err != nil {
+			return imported, err
+		}
+		imported++
+	}
+	return imported, nil
+}
+
+// Remove drops the record saved for url by service
+func (store *Store) Remove(service, url string) error {
+	db, err := store.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(service))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.Delete([]byte(url))
+	})
+}
+
+// Prune drops every record for which drop returns true, and returns the dropped records
+func (store *Store) Prune(drop func(rec Record) bool) ([]Record, error) {
+	dropped := []Record{}
+	db, err := store.open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	err = db.Update(func(tx *bolt.Tx) error {
+		doomed := map[*bolt.Bucket][]Record{} // bolt does not like deleting keys while iterating over them
+		err := eachRecord(tx, func(bucket *bolt.Bucket, rec Record) error {
+			if drop(rec) {
+				doomed[bucket] = append(doomed[bucket], rec)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for bucket, records := range doomed {
+			for _, rec := range records {
+				if err := bucket.Delete([]byte(rec.URL)); err != nil {
+					return err
+				}
+				dropped = append(dropped, rec)
+			}
+		}
+		return nil
+	})
+	return dropped, err
+}
+
+// GC drops expired records and those marked gone; with probe, records whose service says the file is gone are dropped as well
+func (store *Store) GC(ctx context.Context, probe bool) ([]Record, error) {
+	records, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	dead := map[string]bool{}
+	for _, rec := range records {
+		if rec.Expired(now) || rec.GoneAt.IsZero() == false {
+			dead[rec.URL] = true
+			continue
+		}
+		if probe == false {
+			continue
+		}
+		backend, ok := LookupStoreName(rec.Service)
+		if ok == false {
+			continue
+		}
+		svc, err := backend.NewService(nil)
+		if err != nil {
+			continue
+		}
+		if retrier, ok := svc.(Retrier); ok {
+			retrier.SetRetryPolicy(RetryPolicy{}) // a failed probe only keeps the record; not worth waiting for
+		}
+		if prober, ok := svc.(Prober); ok {
+			// a failed probe tells us nothing; keep the record
+			if alive, err := prober.Alive(ctx, rec); err == nil && alive == false {
+				dead[rec.URL] = true
+			}
+		}
+	}
+	return store.Prune(func(rec Record) bool { return dead[rec.URL] })
+}
+
+// eachRecord calls fn on every record of every service bucket
+func eachRecord(tx *bolt.Tx, fn func(bucket *bolt.Bucket, rec Record) error) error {
+	return tx.ForEach(func(name []byte, bucket *bolt.Bucket) error {
+		if string(name) == metaBucketName {
+			return nil
+		}
+		return bucket.ForEach(func(key, value []byte) error {
+			var rec Record
+			if err := json.Unmarshal(value, &rec); err != nil {
+				return fmt.Errorf("bad record for %s: %s", key, err)
+			}
+			return fn(bucket, rec)
+		})
+	})
+}
+
+// migrate upgrades the db to schemaVersion; databases written before records existed map urls to bare delete urls
+func migrate(tx *bolt.Tx) error {
+	meta, err := tx.CreateBucketIfNotExists([]byte(metaBucketName))
+	if err != nil {
+		return err
+	}
+	if version := meta.Get([]byte("version")); version != nil && version[0] >= schemaVersion {
+		return nil
+	}
+	err = tx.ForEach(func(name []byte, bucket *bolt.Bucket) error {
+		if string(name) == metaBucketName {
+			return nil
+		}
+		legacy := map[string][]byte{}
+		bucket.ForEach(func(key, value []byte) error {
+			if bytes.HasPrefix(value, []byte("{")) == false {
+				legacy[string(key)] = append([]byte{}, value...)
+			}
+			return nil
+		})
+		for url, deleteUrl := range legacy {
+			value, err := json.Marshal(Record{Service: string(name), URL: url, DeleteURL: string(deleteUrl)})
+			if err != nil {
+				return err
+			}
+			if err = bucket.Put([]byte(url), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return meta.Put([]byte("version"), []byte{schemaVersion})
+}
@@ -0,0 +1,197 @@
+package sendall
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestStoreMigratesLegacyRecords(t *testing.T) {
+	const (
+		legacyUrl       = "https://transfer.sh/abcde/hostname"
+		legacyDeleteUrl = "https://transfer.sh/abcde/hostname/0123456789"
+	)
+	defer os.Remove(validDbName)
+
+	// a db as written before records were json: url -> bare delete url
+	db, err := bolt.Open(validDbName, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(validBucketName))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(legacyUrl), []byte(legacyDeleteUrl))
+	})
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore(validDbName)
+	rec, err := store.Find(validBucketName, legacyUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.DeleteURL != legacyDeleteUrl || rec.Service != validBucketName {
+		t.Errorf("legacy record was not migrated properly: %+v", rec)
+	}
+
+	// new records live next to migrated ones
+	if err = store.Save(UploadResult{Service: "privateBin", URL: "https://bin.example/?abc#key", DeleteURL: "https://bin.example/?pasteid=abc&deletetoken=def", ExpiresAt: time.Now().Add(-time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+
+	dropped, err := store.Prune(func(rec Record) bool { return rec.Service == "privateBin" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dropped) != 1 {
+		t.Errorf("expected 1 pruned record, got %d", len(dropped))
+	}
+	if _, err = store.Get(legacyUrl); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStorePruneBadRecord(t *testing.T) {
+	defer os.Remove(validDbName)
+	store := NewStore(validDbName)
+	if err := store.Save(UploadResult{Service: validBucketName, URL: "https://transfer.sh/abcde/good"}); err != nil {
+		t.Fatal(err)
+	}
+	db, err := bolt.Open(validDbName, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(validBucketName)).Put([]byte("https://transfer.sh/abcde/bad"), []byte("{not json"))
+	})
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dropped, err := store.Prune(func(rec Record) bool { return true })
+	if err == nil {
+		t.Error("pruned a db with a bad record without an error")
+	}
+	if len(dropped) != 0 {
+		t.Errorf("expected nothing pruned, got %+v", dropped)
+	}
+}
+
+func TestStoreGCDropsExpiredRecords(t *testing.T) {
+	defer os.Remove(validDbName)
+	store := NewStore(validDbName)
+	expired := UploadResult{Service: validBucketName, URL: "https://transfer.sh/abcde/old", ExpiresAt: time.Now().Add(-time.Minute)}
+	fresh := UploadResult{Service: validBucketName, URL: "https://transfer.sh/abcde/new", ExpiresAt: time.Now().Add(time.Hour)}
+	forever := UploadResult{Service: validBucketName, URL: "https://transfer.sh/abcde/forever"}
+	for _, result := range []UploadResult{expired, fresh, forever} {
+		if err := store.Save(result); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dropped, err := store.GC(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dropped) != 1 || dropped[0].URL != expired.URL {
+		t.Errorf("expected only %s to be dropped, got %+v", expired.URL, dropped)
+	}
+}
+
+func TestStoreGCProbes(t *testing.T) {
+	defer os.Remove(validDbName)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "HEAD" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		status, _ := strconv.Atoi(strings.Split(req.URL.Path, "/")[1])
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	store := NewStore(validDbName)
+	var tests = []struct {
+		status  int
+		dropped bool
+	}{
+		{http.StatusOK, false},
+		{http.StatusNotFound, true}, // expired or deleted
+		{http.StatusGone, true},
+		{http.StatusInternalServerError, false}, // tells nothing about the file
+	}
+	for _, test := range tests {
+		result := UploadResult{Service: "transfer.sh", URL: fmt.Sprintf("%s/%d/abcde/welp", server.URL, test.status)}
+		if err := store.Save(result); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dropped, err := store.GC(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gone := map[string]bool{}
+	for _, rec := range dropped {
+		gone[rec.URL] = true
+	}
+	for _, test := range tests {
+		if url := fmt.Sprintf("%s/%d/abcde/welp", server.URL, test.status); gone[url] != test.dropped {
+			t.Errorf("%d: expected dropped %v, got %v", test.status, test.dropped, gone[url])
+		}
+	}
+	if records, _ := store.List(); len(records) != 2 {
+		t.Errorf("expected 2 records left, got %d", len(records))
+	}
+}
+
+func TestStoreMarkGone(t *testing.T) {
+	defer os.Remove(validDbName)
+	store := NewStore(validDbName)
+	burned := UploadResult{Service: "privateBin", URL: "https://bin.example.com/?0123456789abcdef#key", MaxDownloads: 1}
+	if err := store.Save(burned); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.MarkGone(burned.URL); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := store.Get(burned.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.GoneAt.IsZero() {
+		t.Error("record was not marked gone")
+	}
+	if err = store.MarkGone("https://bin.example.com/?fedcba9876543210#key"); err == nil {
+		t.Error("marked a link that is not in the history")
+	}
+
+	dropped, err := store.GC(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dropped) != 1 || dropped[0].URL != burned.URL {
+		t.Errorf("expected %s to be dropped, got %+v", burned.URL, dropped)
+	}
+}
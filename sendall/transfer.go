@@ -0,0 +1,423 @@
+package sendall
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcutil/base58"
+)
+
+const (
+	serverDev  = "http://127.0.0.1"
+	portDev    = "8090"
+	serverProd = "https://transfer.sh"
+)
+
+var (
+	transferReqHeaders = []string{ // any custom headers used in issuing the request; for reference only
+		"Max-Downloads",
+		"Max-Days",
+		"X-Encrypt-Password", // on PUT; the server encrypts the file at rest with gpg
+		"X-Decrypt-Password", // on GET
+	}
+	transferRespHeaders = []string{ // any custom headers received on response; for reference only
+		"X-Url-Delete",
+		"X-Remaining-Downloads", // on GET; "n/a" if unlimited
+		"X-Remaining-Days",
+	}
+)
+
+// TransferShOptions : options of the transfer.sh service
+type TransferShOptions struct {
+	Host           string // service URL, for example if you host your own instance
+	MaxDownloads   int    // number of downloads after which the link expires; -1 for no limit
+	MaxDays        int    // number of days after which the file is removed from the server
+	Encrypt        bool   // encrypt files before sending them; the key goes in the fragment of the link
+	ServerPassword string // have the server encrypt files at rest with this password (newer servers only); never stored
+}
+
+// DefaultTransferShOptions returns the options used by https://transfer.sh
+func DefaultTransferShOptions() TransferShOptions {
+	return TransferShOptions{
+		Host:         serverProd,
+		MaxDownloads: -1,
+		MaxDays:      7,
+	}
+}
+
+// TransferSh : the transfer.sh service (credits go to DutchCoders)
+type TransferSh struct {
+	Options    TransferShOptions
+	HTTPClient *http.Client
+}
+
+// NewTransferSh returns a transfer.sh service using the default http client, retrying by DefaultRetryPolicy()
+func NewTransferSh(options TransferShOptions) *TransferSh {
+	return &TransferSh{Options: options, HTTPClient: retryingClient(&http.Client{}, DefaultRetryPolicy())}
+}
+
+// SetRetryPolicy : requests failing for a transient reason are retried by policy from now on
+func (receiver *TransferSh) SetRetryPolicy(policy RetryPolicy) {
+	receiver.HTTPClient = retryingClient(receiver.HTTPClient, policy)
+}
+
+func init() {
+	defaults := DefaultTransferShOptions()
+	Register(Backend{
+		Name:        "transfer",
+		StoreName:   "transfer.sh",
+		Description: "use transfer.sh service (credits go to DutchCoders)",
+		Options: []Option{
+			{Name: "downloads", Shorthand: "e", Default: defaults.MaxDownloads, Usage: "Maximum number of downloads after which the link will expire"},
+			{Name: "days", Shorthand: "d", Default: defaults.MaxDays, Usage: "Maximum number of days after which the file will be removed from the server"},
+			{Name: "host", Shorthand: "u", Default: defaults.Host, Usage: "service URL, for example if you host your own instance"},
+			{Name: "encrypt", Default: defaults.Encrypt, Usage: "encrypt files before they leave the machine; the key goes in the fragment of the link, which is never sent to the server"},
+			{Name: "server-password", Default: "", Secret: true, Usage: "have the server encrypt files at rest with this password, which downloads need too; \"-\" asks for it"},
+		},
+		Capabilities: Capabilities{Delete: true, Download: true, Encryption: true, MaxSize: 10 << 30}, // transfer.sh caps uploads at 10GB; encryption with --encrypt
+		New: func(values Values) (Service, error) {
+			return NewTransferSh(TransferShOptions{
+				Host:           values.String("host"),
+				MaxDownloads:   values.Int("downloads"),
+				MaxDays:        values.Int("days"),
+				Encrypt:        values.Bool("encrypt"),
+				ServerPassword: values.String("server-password"),
+			}), nil
+		},
+	})
+}
+
+func (receiver *TransferSh) Name() string {
+	return "transfer.sh"
+}
+
+func (receiver *TransferSh) Upload(ctx context.Context, upload UploadRequest) (UploadResult, error) {
+
+	var (
+		body       io.ReadCloser
+		url        string
+		newRequest *http.Request
+		resp       *http.Response
+		respBody   []byte
+		key        []byte
+		sum        hash.Hash // recorded, so that downloads can be checked against it
+		counter    *countingReader
+		start      int64 // where upload.Body starts, if it can seek
+		err        error
+	)
+	result := UploadResult{Service: receiver.Name(), File: upload.Path}
+	if receiver.Options.Encrypt {
+		key = make([]byte, streamKeySize)
+		if _, err = io.ReadFull(rand.Reader, key); err != nil {
+			return result, err
+		}
+		result.Key = base58.Encode(key)
+	}
+	// files are read again from the start on a retry, as the failed try consumed them; stdin can't be, unless it's a file
+	replayable := upload.Body == nil
+	if seeker, ok := upload.Body.(io.Seeker); ok {
+		start, err = seeker.Seek(0, io.SeekCurrent)
+		replayable = err == nil
+	}
+	open := func() (io.ReadCloser, error) {
+		var (
+			reader io.Reader
+			closer io.Closer = ioutil.NopCloser(upload.Body) // upload.Body is closed by whoever handed it over
+		)
+		if upload.Body == nil {
+			file, err := os.Open(upload.Path)
+			if err != nil {
+				return nil, err
+			}
+			reader, closer = bufio.NewReader(file), file
+		} else {
+			if counter != nil { // a retry
+				if _, err := upload.Body.(io.Seeker).Seek(start, io.SeekStart); err != nil {
+					return nil, err
+				}
+			}
+			reader = upload.Body
+		}
+		sum = sha256.New()
+		counter = uploadCounter(io.TeeReader(reader, sum), upload)
+		reader = counter
+		if key != nil {
+			encrypted, err := NewEncryptReader(counter, key)
+			if err != nil {
+				closer.Close()
+				return nil, err
+			}
+			reader = encrypted
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{reader, closer}, nil
+	}
+	if body, err = open(); err != nil {
+		return result, err
+	}
+	name := upload.Name
+	if name == "" {
+		name = upload.Path
+	}
+	url = receiver.Options.Host + "/" + sanitize(name)                  // TODO: imo we only need filepath.Clean(file.Name())
+	newRequest, err = http.NewRequestWithContext(ctx, "PUT", url, body) // transfer.sh resolves file path and generates a folder with random name
+	if err != nil {
+		body.Close()
+		return result, err
+	}
+	if replayable {
+		newRequest.GetBody = open
+	}
+	// adding custom headers
+	newRequest.Header.Add("Max-Downloads", strconv.Itoa(receiver.Options.MaxDownloads)) // TODO: Itoa() all the fields ?
+	newRequest.Header.Add("Max-Days", strconv.Itoa(receiver.Options.MaxDays))
+	if receiver.Options.ServerPassword != "" {
+		newRequest.Header.Set("X-Encrypt-Password", receiver.Options.ServerPassword)
+	}
+
+	if resp, err = receiver.HTTPClient.Do(newRequest); err != nil {
+		return result, fmt.Errorf("issuing request failed: %s", err)
+	}
+	defer resp.Body.Close()
+	if respBody, err = ioutil.ReadAll(resp.Body); err != nil {
+		return result, fmt.Errorf("failed to read body: %s", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return result, fmt.Errorf("server replied with %s", resp.Status)
+	}
+	result.Size = counter.count
+	result.SHA256 = hex.EncodeToString(sum.Sum(nil))
+	result.URL = strings.TrimSpace(string(respBody)) // body is new url returned by the server
+	if result.Key != "" {
+		result.URL += "#" + result.Key
+	}
+	result.DeleteURL = resp.Header.Get("X-Url-Delete")
+	if receiver.Options.MaxDays > 0 {
+		result.ExpiresAt = time.Now().AddDate(0, 0, receiver.Options.MaxDays)
+	}
+	if receiver.Options.MaxDownloads > 0 {
+		result.MaxDownloads = receiver.Options.MaxDownloads
+	}
+	return result, nil
+}
+
+// UploadBundle posts every upload in one multipart form and returns the link to the archive transfer.sh builds out of them.
+// transfer.sh only hands back the delete url of the last file of a form, so the bundle can't be deleted
+func (receiver *TransferSh) UploadBundle(ctx context.Context, uploads []UploadRequest, format string) (UploadResult, error) {
+	var (
+		newRequest *http.Request
+		resp       *http.Response
+		respBody   []byte
+		err        error
+	)
+	result := UploadResult{Service: receiver.Name()}
+	if format != ArchiveZip && format != ArchiveTarGz {
+		return result, fmt.Errorf("unknown archive format %s; values: [%s, %s]", format, ArchiveZip, ArchiveTarGz)
+	}
+	files := make([]string, len(uploads))
+	for i, upload := range uploads {
+		files[i] = upload.Path
+	}
+	result.File = strings.Join(files, ",")
+	if receiver.Options.Encrypt || receiver.Options.ServerPassword != "" { // the server would archive ciphertexts that no one can open on their own
+		return result, errors.New("encrypted files can't be bundled; upload them one by one, or as an --archive")
+	}
+
+	// stream the form; files are never held in memory
+	reader, writer := io.Pipe()
+	defer reader.Close()
+	form := multipart.NewWriter(writer)
+	counter := &countingReader{reader: reader}
+	go func() {
+		writer.CloseWithError(writeForm(form, uploads))
+	}()
+
+	if newRequest, err = http.NewRequestWithContext(ctx, "POST", receiver.Options.Host+"/", counter); err != nil {
+		return result, err
+	}
+	newRequest.Header.Set("Content-Type", form.FormDataContentType())
+	newRequest.Header.Add("Max-Downloads", strconv.Itoa(receiver.Options.MaxDownloads))
+	newRequest.Header.Add("Max-Days", strconv.Itoa(receiver.Options.MaxDays))
+	if resp, err = receiver.HTTPClient.Do(newRequest); err != nil {
+		return result, fmt.Errorf("issuing request failed: %s", err)
+	}
+	defer resp.Body.Close()
+	if respBody, err = ioutil.ReadAll(resp.Body); err != nil {
+		return result, fmt.Errorf("failed to read body: %s", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return result, fmt.Errorf("server replied with %s", resp.Status)
+	}
+
+	// the body holds one url per file: $host/$token/$filename; the bundle is $host/($token/$filename,...).$format
+	var entries []string
+	for _, line := range strings.Fields(string(respBody)) {
+		if strings.HasPrefix(line, receiver.Options.Host+"/") == false {
+			return result, fmt.Errorf("unexpected url in response: %s", line)
+		}
+		entries = append(entries, strings.TrimPrefix(line, receiver.Options.Host+"/"))
+	}
+	if len(entries) == 0 {
+		return result, fmt.Errorf("server replied without any url")
+	}
+	result.Size = counter.count
+	result.URL = fmt.Sprintf("%s/(%s).%s", receiver.Options.Host, strings.Join(entries, ","), format)
+	if receiver.Options.MaxDays > 0 {
+		result.ExpiresAt = time.Now().AddDate(0, 0, receiver.Options.MaxDays)
+	}
+	return result, nil
+}
+
+// writeForm writes every upload as a file of the form; directories are walked, each file becoming a part of its own
+func writeForm(form *multipart.Writer, uploads []UploadRequest) error {
+	for _, upload := range uploads {
+		var err error
+		if upload.Body != nil {
+			err = writeFormFile(form, upload.Name, func(part io.Writer) error {
+				_, err := io.Copy(part, upload.Body)
+				return err
+			})
+		} else {
+			err = walkFiles([]string{upload.Path}, func(path, name string, info os.FileInfo) error {
+				if upload.Name != "" && info.Name() == filepath.Base(upload.Path) {
+					name = upload.Name // a single file renamed with --name
+				}
+				return writeFormFile(form, name, func(part io.Writer) error { return copyFile(part, path) })
+			})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return form.Close()
+}
+
+func writeFormFile(form *multipart.Writer, name string, write func(part io.Writer) error) error {
+	part, err := form.CreateFormFile(sanitize(name), sanitize(name))
+	if err != nil {
+		return err
+	}
+	return write(part)
+}
+
+func (receiver *TransferSh) Delete(ctx context.Context, rec Record) error {
+
+	var (
+		req  *http.Request
+		resp *http.Response
+		err  error
+	)
+	if req, err = http.NewRequestWithContext(ctx, "DELETE", rec.DeleteURL, nil); err != nil {
+		return err
+	}
+	if resp, err = receiver.HTTPClient.Do(req); err != nil {
+		return fmt.Errorf("issuing request failed: %s", err)
+	}
+	defer resp.Body.Close()
+	// TODO: assume here we got a 200 response code (what is 200 for transfer ?)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("method not allowed (invalid url or file was deleted)")
+	}
+	return nil
+}
+
+func (receiver *TransferSh) Alive(ctx context.Context, rec Record) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", rec.URL, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := receiver.HTTPClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound, http.StatusGone: // expired, deleted or burned by the download limit
+		return false, nil
+	default:
+		return false, fmt.Errorf("server replied with %s", resp.Status)
+	}
+}
+
+// DownloadInfo : what the server tells about a file being downloaded
+type DownloadInfo struct {
+	Offset             int64  // where the body starts in the file; 0 unless a resume was asked for and honored
+	Size               int64  // of the whole file; -1 if the server didn't say
+	RemainingDownloads string // as the server puts it; "n/a" when unlimited, empty if not sent
+	RemainingDays      string
+}
+
+// Download fetches the file at fileUrl from offset on (to resume an earlier download); the caller reads and
+// closes the body. servers that ignore the range send the whole file again, which info.Offset tells
+func (receiver *TransferSh) Download(ctx context.Context, fileUrl string, offset int64) (io.ReadCloser, DownloadInfo, error) {
+	info := DownloadInfo{Size: -1}
+	req, err := http.NewRequestWithContext(ctx, "GET", fileUrl, nil)
+	if err != nil {
+		return nil, info, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	if receiver.Options.ServerPassword != "" {
+		req.Header.Set("X-Decrypt-Password", receiver.Options.ServerPassword)
+	}
+	resp, err := receiver.HTTPClient.Do(req)
+	if err != nil {
+		return nil, info, err
+	}
+	info.RemainingDownloads = resp.Header.Get("X-Remaining-Downloads")
+	info.RemainingDays = resp.Header.Get("X-Remaining-Days")
+
+	var start, end int64
+	switch resp.StatusCode {
+	case http.StatusOK:
+		info.Size = resp.ContentLength
+	case http.StatusPartialContent: // Content-Range: bytes 100-999/1000
+		_, err = fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &info.Size)
+		if err != nil || start != offset {
+			resp.Body.Close()
+			return nil, info, fmt.Errorf("bad Content-Range %q", resp.Header.Get("Content-Range"))
+		}
+		info.Offset = offset
+	case http.StatusRequestedRangeNotSatisfiable: // Content-Range: bytes */1000; nothing past offset, so it was all downloaded already
+		resp.Body.Close()
+		_, err = fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes */%d", &info.Size)
+		if err != nil || info.Size != offset {
+			return nil, info, fmt.Errorf("can't resume at byte %d: server replied with %s", offset, resp.Status)
+		}
+		info.Offset = offset
+		return ioutil.NopCloser(strings.NewReader("")), info, nil
+	case http.StatusNotFound, http.StatusGone:
+		resp.Body.Close()
+		return nil, info, ErrGone
+	default:
+		resp.Body.Close()
+		return nil, info, fmt.Errorf("server replied with %s", resp.Status)
+	}
+	return resp.Body, info, nil
+}
+
+// PUT: /put/$filename, /upload/$filename, /$filename
+// POST: /
+// DELETE: /$token/$filename/$deletiontoken		// provided by default by the server
+// GET: /$token/$filename
+// HEAD: /$token/$filename
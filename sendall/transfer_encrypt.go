@@ -0,0 +1,195 @@
+package sendall
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/btcsuite/btcutil/base58"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+// files uploaded with --encrypt are encrypted on the fly, following the STREAM construction of age
+// (https://age-encryption.org/v1): the file is cut in chunks of 64KB, each sealed with chacha20poly1305 under a
+// nonce made of its index and a flag set on the last one, so chunks can't be reordered, dropped or cut off.
+//
+//	magic | nonce (16 bytes) | sealed chunks
+//
+// the key of the chunks is derived from the key in the link, salted with the nonce, so that no key is used twice
+const (
+	streamMagic     = "sendall-stream/v1\n"
+	streamNonceSize = 16
+	streamChunkSize = 64 << 10
+	streamKeySize   = chacha20poly1305.KeySize
+)
+
+// ErrBadStream : an encrypted file did not open with the key given
+var ErrBadStream = errors.New("could not decrypt the file (wrong key, or the file was tampered with or cut short)")
+
+// TransferKey returns the key in the fragment of a link uploaded with --encrypt, or nil if the link has none
+func TransferKey(fileUrl string) ([]byte, error) {
+	parsed, err := url.Parse(fileUrl)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Fragment == "" {
+		return nil, nil
+	}
+	key := base58.Decode(parsed.Fragment)
+	if len(key) != streamKeySize {
+		return nil, fmt.Errorf("bad key in %s", fileUrl)
+	}
+	return key, nil
+}
+
+func streamCipher(key, nonce []byte) (cipher.AEAD, error) {
+	chunkKey := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, nonce, []byte("payload")), chunkKey); err != nil {
+		return nil, err
+	}
+	return chacha20poly1305.New(chunkKey)
+}
+
+// chunkNonce is the big endian index of the chunk, followed by 1 for the last chunk and 0 otherwise
+func chunkNonce(index uint64, last bool) []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	binary.BigEndian.PutUint64(nonce[3:11], index)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// lastChunk tells whether the chunk just read from source was the last one; only an empty file has an empty last chunk
+func lastChunk(source *bufio.Reader, err error) (bool, error) {
+	switch err {
+	case io.EOF, io.ErrUnexpectedEOF:
+		return true, nil
+	case nil:
+		if _, err = source.Peek(1); err == io.EOF {
+			return true, nil
+		}
+		return false, err
+	default:
+		return false, err
+	}
+}
+
+type encryptReader struct {
+	source  *bufio.Reader
+	aead    cipher.AEAD
+	index   uint64
+	last    bool
+	chunk   []byte
+	sealed  []byte
+	pending []byte // sealed bytes not read yet
+}
+
+// NewEncryptReader returns a reader of source encrypted with key (32 bytes long); only a chunk is held in memory at a time
+func NewEncryptReader(source io.Reader, key []byte) (io.Reader, error) {
+	nonce := make([]byte, streamNonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	aead, err := streamCipher(key, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptReader{
+		source:  bufio.NewReader(source),
+		aead:    aead,
+		chunk:   make([]byte, streamChunkSize),
+		sealed:  make([]byte, 0, streamChunkSize+aead.Overhead()),
+		pending: append([]byte(streamMagic), nonce...),
+	}, nil
+}
+
+func (stream *encryptReader) Read(p []byte) (int, error) {
+	for len(stream.pending) == 0 {
+		if stream.last {
+			return 0, io.EOF
+		}
+		if err := stream.seal(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, stream.pending)
+	stream.pending = stream.pending[n:]
+	return n, nil
+}
+
+func (stream *encryptReader) seal() error {
+	n, err := io.ReadFull(stream.source, stream.chunk)
+	if stream.last, err = lastChunk(stream.source, err); err != nil {
+		return err
+	}
+	stream.pending = stream.aead.Seal(stream.sealed[:0], chunkNonce(stream.index, stream.last), stream.chunk[:n], nil)
+	stream.index++
+	return nil
+}
+
+type decryptReader struct {
+	source  *bufio.Reader
+	aead    cipher.AEAD
+	index   uint64
+	last    bool
+	sealed  []byte
+	chunk   []byte
+	pending []byte // opened bytes not read yet
+}
+
+// NewDecryptReader reverses NewEncryptReader(); reads fail with ErrBadStream if the key is wrong or the file was altered
+func NewDecryptReader(source io.Reader, key []byte) (io.Reader, error) {
+	header := make([]byte, len(streamMagic)+streamNonceSize)
+	if _, err := io.ReadFull(source, header); err != nil || string(header[:len(streamMagic)]) != streamMagic {
+		return nil, errors.New("not a file encrypted by sendall")
+	}
+	aead, err := streamCipher(key, header[len(streamMagic):])
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{
+		source: bufio.NewReader(source),
+		aead:   aead,
+		sealed: make([]byte, streamChunkSize+aead.Overhead()),
+		chunk:  make([]byte, 0, streamChunkSize),
+	}, nil
+}
+
+func (stream *decryptReader) Read(p []byte) (int, error) {
+	for len(stream.pending) == 0 {
+		if stream.last {
+			return 0, io.EOF
+		}
+		if err := stream.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, stream.pending)
+	stream.pending = stream.pending[n:]
+	return n, nil
+}
+
+func (stream *decryptReader) open() error {
+	n, err := io.ReadFull(stream.source, stream.sealed)
+	if err == io.EOF { // the last chunk went missing
+		return ErrBadStream
+	}
+	if stream.last, err = lastChunk(stream.source, err); err != nil {
+		return err
+	}
+	chunk, err := stream.aead.Open(stream.chunk[:0], chunkNonce(stream.index, stream.last), stream.sealed[:n], nil)
+	if err != nil || (stream.last && len(chunk) == 0 && stream.index > 0) {
+		return ErrBadStream
+	}
+	stream.pending = chunk
+	stream.index++
+	return nil
+}
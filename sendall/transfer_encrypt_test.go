@@ -0,0 +1,144 @@
+package sendall
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func encryptBytes(t *testing.T, plaintext, key []byte) []byte {
+	stream, err := NewEncryptReader(bytes.NewReader(plaintext), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := ioutil.ReadAll(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ciphertext
+}
+
+func decryptBytes(ciphertext, key []byte) ([]byte, error) {
+	stream, err := NewDecryptReader(bytes.NewReader(ciphertext), key)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(stream)
+}
+
+func TestEncryptStream(t *testing.T) {
+	key := make([]byte, streamKeySize)
+	rand.Read(key)
+	for _, size := range []int{0, 1, streamChunkSize - 1, streamChunkSize, streamChunkSize + 1, 3*streamChunkSize + 5} {
+		plaintext := make([]byte, size)
+		rand.Read(plaintext)
+		ciphertext := encryptBytes(t, plaintext, key)
+		chunks := (size + streamChunkSize - 1) / streamChunkSize
+		if chunks == 0 {
+			chunks = 1 // an empty file still has its (empty) last chunk
+		}
+		if want := len(streamMagic) + streamNonceSize + size + chunks*16; len(ciphertext) != want {
+			t.Errorf("size %d: expected %d bytes of ciphertext, got %d", size, want, len(ciphertext))
+		}
+		decrypted, err := decryptBytes(ciphertext, key)
+		if err != nil {
+			t.Errorf("size %d: %s", size, err)
+			continue
+		}
+		if bytes.Equal(decrypted, plaintext) == false {
+			t.Errorf("size %d: decrypted text differs", size)
+		}
+	}
+}
+
+func TestEncryptStreamTampered(t *testing.T) {
+	key := make([]byte, streamKeySize)
+	rand.Read(key)
+	plaintext := make([]byte, 2*streamChunkSize+100)
+	ciphertext := encryptBytes(t, plaintext, key)
+	header := len(streamMagic) + streamNonceSize
+	sealedChunk := streamChunkSize + 16
+
+	flipped := append([]byte{}, ciphertext...)
+	flipped[header+sealedChunk+7] ^= 1
+	otherKey := make([]byte, streamKeySize)
+	rand.Read(otherKey)
+	var tests = []struct {
+		name       string
+		ciphertext []byte
+		key        []byte
+	}{
+		{"flipped bit", flipped, key},
+		{"cut at a chunk", ciphertext[:header+sealedChunk], key},
+		{"cut in a chunk", ciphertext[:len(ciphertext)-10], key},
+		{"chunks swapped", append(append(append([]byte{}, ciphertext[:header]...), ciphertext[header+sealedChunk:header+2*sealedChunk]...), ciphertext[header:header+sealedChunk]...), key},
+		{"wrong key", ciphertext, otherKey},
+	}
+	for _, test := range tests {
+		if _, err := decryptBytes(test.ciphertext, test.key); err != ErrBadStream {
+			t.Errorf("%s: expected ErrBadStream, got %v", test.name, err)
+		}
+	}
+	if _, err := decryptBytes([]byte("plain old file"), key); err == nil {
+		t.Error("decrypted a file that was never encrypted")
+	}
+}
+
+func TestEncryptedUploadAndDownload(t *testing.T) {
+	var stored []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "PUT" {
+			stored, _ = ioutil.ReadAll(req.Body)
+			io.WriteString(w, "http://"+req.Host+"/abcde"+req.URL.Path)
+			return
+		}
+		if req.URL.Fragment != "" {
+			t.Error("the key was sent to the server")
+		}
+		http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(stored))
+	}))
+	defer server.Close()
+	options := DefaultTransferShOptions()
+	options.Host, options.Encrypt = server.URL, true
+	transfer := NewTransferSh(options)
+
+	content := strings.Repeat("not for the server's eyes\n", 10000)
+	result, err := transfer.Upload(context.Background(), UploadRequest{Name: "secret.txt", Body: strings.NewReader(content)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(stored, []byte("server's eyes")) {
+		t.Error("the server received plaintext")
+	}
+	if result.Size != int64(len(content)) || strings.HasSuffix(result.URL, "#"+result.Key) == false {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	key, err := TransferKey(result.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _, err := transfer.Download(context.Background(), result.URL, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	plain, err := NewDecryptReader(body, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ioutil.ReadAll(plain); err != nil || string(got) != content {
+		t.Errorf("downloaded file differs (%v)", err)
+	}
+
+	if _, err = transfer.UploadBundle(context.Background(), []UploadRequest{{Path: "/etc/hostname"}}, ArchiveZip); err == nil {
+		t.Error("bundled encrypted files")
+	}
+}
@@ -0,0 +1,373 @@
+package sendall
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	// "errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	globalHttpClient http.Client // one client fits all
+	regexResponse    = regexp.MustCompile(`^https?://.*?(:\d{2,5})?/\w{5,6}/`)
+	testDb           = make(map[string]string)
+)
+
+const (
+	// serverTest      = "http://127.0.0.1:8090/"
+	validDbName     = "sendall_test.db"
+	validBucketName = "transfer.sh"
+	// characters used for short-urls
+	SYMBOLS = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+	// someone set us up the bomb !!
+	BASE = int64(len(SYMBOLS))
+)
+
+/*
+https://github.com/fs111/kurz.go/blob/master/src/codec.go
+
+Originally written and Copyright (c) 2011 André Kelpe
+Modifications Copyright (c) 2015 John Ko
+
+// encodeToTokens a number into our *base* representation
+// TODO can this be made better with some bitshifting?
+// TODO: this is VERY SLOW!
+*/
+func encodeToToken(number int64) string {
+	rest := number % BASE
+	// strings are a bit weird in go...
+	result := string(SYMBOLS[rest])
+	if number-rest != 0 {
+		newnumber := (number - rest) / BASE
+		result = encodeToToken(newnumber) + result
+	}
+	return result
+}
+
+type transferShTest struct {
+	TransferSh          // struct is embedded into the test struct
+	filePaths  []string // ready and clean to be read from
+	shouldFail bool     // when a test returns a valid err, it should not be marked as failure, because the input was already malformed; i am definitely structuring this wrong;
+}
+
+func uploadHandler(w http.ResponseWriter, req *http.Request) {
+	// a mock handler that always returns a valid URL
+	// this should be changed soon
+
+	// If WriteHeader is not called explicitly, the first call to Write
+	// will trigger an implicit WriteHeader(http.StatusOK).
+	// w.WriteHeader(http.StatusOK)
+	// io.WriteString(w, req.URL)
+	uploadToken := encodeToToken(10000000 + int64(rand.Intn(1000000000)))
+	deleteToken := encodeToToken(10000000+int64(rand.Intn(1000000000))) + encodeToToken(10000000+int64(rand.Intn(1000000000)))
+	uploadUrl := fmt.Sprintf("http://%s/%s%s", req.Host, uploadToken, req.URL)
+	deleteUrl := fmt.Sprintf("%s/%s", uploadUrl, deleteToken)
+
+	w.Header().Set("Server", "Transfer.sh HTTP Server 1.0")
+	w.Header().Set("X-Made-With", "<3 by DutchCoders")
+	w.Header().Set("X-Served-By", "Proudly served by DutchCoders")
+	w.Header().Set("X-Url-Delete", deleteUrl)
+	io.WriteString(w, uploadUrl)
+}
+
+func deleteHandler(w http.ResponseWriter, req *http.Request) {
+	// if a client has dialed this url, then we're sure it's the delete url.
+	// Delete() searches the database for the delete link, and issue a delete
+	// request. since this handler is called after being matching with a regex,
+	// it's likely his is a legit request
+	w.WriteHeader(http.StatusOK)
+}
+
+func SimulatePostRequest(transfer *transferShTest) (error, []string) {
+
+	// should honor the failure bit and act accordingly
+	// post, then check the returned url
+	postedUrls := []string{}
+	results, errs := UploadFiles(context.Background(), &transfer.TransferSh, transfer.filePaths)
+	for i, result := range results {
+		if errs[i] != nil {
+			return errs[i], postedUrls
+		}
+		if matched := regexResponse.MatchString(result.URL); matched == false {
+			return fmt.Errorf("response string does not match the regex; response is: %s", result.URL), postedUrls
+		}
+		if err := NewStore(validDbName).Save(result); err != nil {
+			return err, postedUrls
+		}
+
+		postedUrls = append(postedUrls, result.URL) // to test Delete() as well
+	}
+	return nil, postedUrls
+
+}
+
+func TestPostAndDelete(t *testing.T) {
+	var (
+		err        error
+		postedUrls []string
+	)
+	// tokens below 62^4 encode to 4 chars, which regexResponse rejects; go seeds math/rand randomly since 1.20,
+	// so pin the draws that used to come from the unseeded source
+	rand.Seed(1)
+	store := NewStore(validDbName)
+	defer os.Remove(validDbName)
+
+	// start the mock server
+	router := mux.NewRouter()
+	// router.Methods("PUT", "DELETE")     // allow only these methods for testing
+	router.HandleFunc("/{token}/{filename}/{deletionToken:\\w{10,12}}", deleteHandler).Methods("DELETE")
+	router.HandleFunc("/put/{filename}", uploadHandler).Methods("PUT")
+	router.HandleFunc("/upload/{filename}", uploadHandler).Methods("PUT")
+	router.HandleFunc("/{filename}", uploadHandler).Methods("PUT")
+
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+	hostUrl := testServer.URL
+
+	// wannabe tests
+	sliceTests := []transferShTest{
+		// hostUrl, maxDOwnloads, maxDays
+		// normal settings
+		{
+			TransferSh: TransferSh{TransferShOptions{hostUrl, -1, 7, false, ""}, &globalHttpClient},
+			filePaths:  []string{"/etc/hostname"},
+			shouldFail: false,
+		},
+
+		// server that does not support the latest version with a valid file
+		// {
+		// 	TransferSh: TransferSh{TransferShOptions{"https://transfer.sh", -1, 7, false, ""}, &globalHttpClient},
+		// 	filePaths:  []string{"/etc/hostname"},
+		// 	shouldFail: false,
+		// },
+
+		// invalid file path (reminder: the []string provided here should contain absolute paths)
+		{
+			TransferSh: TransferSh{TransferShOptions{hostUrl, -1, 7, false, ""}, &globalHttpClient},
+			filePaths:  []string{"/hostname"},
+			shouldFail: true,
+		},
+		{
+			TransferSh: TransferSh{TransferShOptions{hostUrl, -1, 7, false, ""}, &globalHttpClient},
+			filePaths:  []string{"/hostname", "/welp"},
+			shouldFail: true,
+		},
+
+		// valid file paths
+		{
+			TransferSh: TransferSh{TransferShOptions{hostUrl, -1, 7, false, ""}, &globalHttpClient},
+			filePaths:  []string{"/etc/hostname", "/etc/passwd"},
+			shouldFail: false,
+		},
+	}
+
+	// using tests
+	for _, test := range sliceTests {
+		if err, postedUrls = SimulatePostRequest(&test); err != nil && test.shouldFail == false {
+			t.Error(err)
+		}
+		for _, url := range postedUrls {
+			rec, err := store.Find(test.Name(), url)
+			if err != nil {
+				t.Error(err)
+				continue
+			}
+			if err = test.Delete(context.Background(), rec); err != nil && test.shouldFail == false {
+				t.Error(err)
+			}
+			if err = store.Remove(test.Name(), url); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+
+	// a link that was never posted should not be found
+	if _, err = store.Find(validBucketName, hostUrl+"/welp/welp"); err == nil {
+		t.Error("found a record for a link that was never posted")
+	}
+}
+
+func TestDownloadResumes(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	honorRange := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if honorRange == false {
+			req.Header.Del("Range")
+		}
+		w.Header().Set("X-Remaining-Downloads", "6")
+		w.Header().Set("X-Remaining-Days", "13")
+		http.ServeContent(w, req, "welp.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+	transfer := NewTransferSh(DefaultTransferShOptions())
+
+	var tests = []struct {
+		offset, wantOffset int64
+		honorRange         bool
+	}{
+		{0, 0, true},
+		{4000, 4000, true},
+		{int64(len(content)), int64(len(content)), true}, // a download that was complete already
+		{4000, 0, false}, // a server that starts over
+	}
+	for _, test := range tests {
+		honorRange = test.honorRange
+		body, info, err := transfer.Download(context.Background(), server.URL+"/abcde/welp.txt", test.offset)
+		if err != nil {
+			t.Errorf("offset %d: %s", test.offset, err)
+			continue
+		}
+		got, err := ioutil.ReadAll(body)
+		body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Offset != test.wantOffset || info.Size != int64(len(content)) {
+			t.Errorf("offset %d: got offset %d and size %d", test.offset, info.Offset, info.Size)
+		}
+		if bytes.Equal(got, content[info.Offset:]) == false {
+			t.Errorf("offset %d: got %d bytes that don't match the file from %d on", test.offset, len(got), info.Offset)
+		}
+		if info.RemainingDownloads != "6" || info.RemainingDays != "13" {
+			t.Errorf("offset %d: remaining headers were not read: %+v", test.offset, info)
+		}
+	}
+
+	// past the end is not a finished download
+	honorRange = true
+	if _, _, err := transfer.Download(context.Background(), server.URL+"/abcde/welp.txt", int64(len(content))+1); err == nil {
+		t.Error("resumed past the end of the file")
+	}
+}
+
+func TestUploadRecordsSHA256(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(uploadHandler))
+	defer server.Close()
+	options := DefaultTransferShOptions()
+	options.Host = server.URL
+	content := "some content to hash\n"
+	result, err := NewTransferSh(options).Upload(context.Background(), UploadRequest{Name: "welp.txt", Body: strings.NewReader(content)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum := sha256.Sum256([]byte(content)); result.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("wrong sha256 %s", result.SHA256)
+	}
+}
+
+func TestServerPassword(t *testing.T) {
+	const password = "correct horse battery staple"
+	var (
+		stored    []byte
+		encryptPw string
+		downloads int32
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "PUT" {
+			stored, _ = ioutil.ReadAll(req.Body)
+			encryptPw = req.Header.Get("X-Encrypt-Password")
+			io.WriteString(w, "http://"+req.Host+"/abcde"+req.URL.Path)
+			return
+		}
+		atomic.AddInt32(&downloads, 1)
+		if req.Header.Get("X-Decrypt-Password") != encryptPw { // what transfer.sh does when gpg fails
+			http.Error(w, "Could not decrypt file", http.StatusInternalServerError)
+			return
+		}
+		w.Write(stored)
+	}))
+	defer server.Close()
+	options := DefaultTransferShOptions()
+	options.Host, options.ServerPassword = server.URL, password
+	transfer := NewTransferSh(options)
+
+	result, err := transfer.Upload(context.Background(), UploadRequest{Name: "welp.txt", Body: strings.NewReader("welp")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encryptPw != password {
+		t.Errorf("server got password %q", encryptPw)
+	}
+	if record, _ := json.Marshal(NewRecord(result)); bytes.Contains(record, []byte(password)) {
+		t.Error("the password would be stored in the history")
+	}
+	body, _, err := transfer.Download(context.Background(), result.URL, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body.Close()
+
+	transfer.Options.ServerPassword = "wrong"
+	if _, _, err = transfer.Download(context.Background(), result.URL, 0); err == nil {
+		t.Error("downloaded with the wrong password")
+	}
+	if downloads := atomic.LoadInt32(&downloads); downloads != 2 {
+		t.Errorf("expected the wrong password to be tried once, got %d downloads in all", downloads)
+	}
+}
+
+func TestUploadBundle(t *testing.T) {
+	file, err := ioutil.TempFile("", "sendall")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("from a file")
+	file.Close()
+
+	parts := make(map[string]string) // file name -> content
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "POST" || req.Header.Get("Max-Days") != "7" {
+			http.Error(w, "welp", http.StatusBadRequest)
+			return
+		}
+		form, err := req.MultipartReader()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var urls []string
+		for part, err := form.NextPart(); err == nil; part, err = form.NextPart() {
+			content, _ := ioutil.ReadAll(part)
+			parts[part.FileName()] = string(content)
+			urls = append(urls, fmt.Sprintf("http://%s/tok%d/%s", req.Host, len(urls), part.FileName()))
+		}
+		io.WriteString(w, strings.Join(urls, "\n")+"\n") // one url per line
+	}))
+	defer server.Close()
+	options := DefaultTransferShOptions()
+	options.Host = server.URL
+	transfer := NewTransferSh(options)
+
+	uploads := []UploadRequest{{Name: "a.txt", Body: strings.NewReader("from stdin")}, {Path: file.Name(), Name: "b.txt"}}
+	result, err := transfer.UploadBundle(context.Background(), uploads, ArchiveZip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parts["a.txt"] != "from stdin" || parts["b.txt"] != "from a file" || len(parts) != 2 {
+		t.Errorf("server got parts %v", parts)
+	}
+	if want := server.URL + "/(tok0/a.txt,tok1/b.txt).zip"; result.URL != want {
+		t.Errorf("got bundle %s, expected %s", result.URL, want)
+	}
+	if result.Size == 0 || result.ExpiresAt.IsZero() {
+		t.Errorf("size %d and expiry %s were not set", result.Size, result.ExpiresAt)
+	}
+}